@@ -0,0 +1,225 @@
+// Package arweavetest provides a test wallet and decodes ANS-104 data items
+// for tests of code signing Arweave transactions and data items.
+package arweavetest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+const (
+	signatureLength = 512
+	ownerLength     = 512
+)
+
+var (
+	walletOnce sync.Once
+	walletJwk  []byte
+)
+
+// WalletJwk returns the JWK of a 4096 bit wallet, generated once per test
+// binary since generating it takes a while.
+func WalletJwk(t testing.TB) []byte {
+	t.Helper()
+
+	walletOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 4096)
+		if err != nil {
+			panic(err)
+		}
+
+		encode := func(value *big.Int) string {
+			return base64.RawURLEncoding.EncodeToString(value.Bytes())
+		}
+		walletJwk, err = json.Marshal(map[string]string{
+			"kty": "RSA",
+			"n":   encode(key.N),
+			"e":   encode(big.NewInt(int64(key.E))),
+			"d":   encode(key.D),
+			"p":   encode(key.Primes[0]),
+			"q":   encode(key.Primes[1]),
+		})
+		if err != nil {
+			panic(err)
+		}
+	})
+
+	return walletJwk
+}
+
+// WriteWallet writes the test wallet to a temporary file and returns its path.
+func WriteWallet(t testing.TB) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "wallet.json")
+	err := os.WriteFile(path, WalletJwk(t), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// VerifySignature checks signature the way gateways and bundlers do.
+func VerifySignature(t testing.TB, owner, message, signature []byte) {
+	t.Helper()
+
+	publicKey := &rsa.PublicKey{N: new(big.Int).SetBytes(owner), E: 65537}
+	digest := sha256.Sum256(message)
+	err := rsa.VerifyPSS(publicKey, crypto.SHA256, digest[:], signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+	if err != nil {
+		t.Errorf("invalid signature: %v", err)
+	}
+}
+
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// DataItem holds the fields of a serialized ANS-104 data item signed with an
+// Arweave wallet.
+type DataItem struct {
+	SignatureType int
+	Signature     []byte
+	Owner         []byte
+	Target        []byte
+	Anchor        []byte
+	TagCount      int
+	RawTags       []byte
+	Tags          []Tag
+	Data          []byte
+}
+
+func ParseDataItem(raw []byte) (DataItem, error) {
+	item := DataItem{}
+	if len(raw) < 2+signatureLength+ownerLength+2+16 {
+		return item, fmt.Errorf("data item of %d bytes is too short", len(raw))
+	}
+
+	item.SignatureType = int(binary.LittleEndian.Uint16(raw))
+	rest := raw[2:]
+	item.Signature, rest = rest[:signatureLength], rest[signatureLength:]
+	item.Owner, rest = rest[:ownerLength], rest[ownerLength:]
+
+	var err error
+	item.Target, rest, err = optionalId(rest)
+	if err != nil {
+		return item, fmt.Errorf("invalid target: %w", err)
+	}
+	item.Anchor, rest, err = optionalId(rest)
+	if err != nil {
+		return item, fmt.Errorf("invalid anchor: %w", err)
+	}
+
+	if len(rest) < 16 {
+		return item, fmt.Errorf("data item is missing its tag lengths")
+	}
+	item.TagCount = int(binary.LittleEndian.Uint64(rest))
+	tagsLength := int(binary.LittleEndian.Uint64(rest[8:]))
+	rest = rest[16:]
+	if len(rest) < tagsLength {
+		return item, fmt.Errorf("tags of %d bytes exceed the data item", tagsLength)
+	}
+	item.RawTags, item.Data = rest[:tagsLength], rest[tagsLength:]
+
+	item.Tags, err = decodeTags(item.RawTags)
+	if err != nil {
+		return item, err
+	}
+	if len(item.Tags) != item.TagCount {
+		return item, fmt.Errorf("expected %d tags, decoded %d", item.TagCount, len(item.Tags))
+	}
+
+	return item, nil
+}
+
+// TagMap returns the tags of the data item by name.
+func (item DataItem) TagMap() map[string]string {
+	tags := map[string]string{}
+	for _, tag := range item.Tags {
+		tags[tag.Name] = tag.Value
+	}
+	return tags
+}
+
+func optionalId(rest []byte) ([]byte, []byte, error) {
+	if len(rest) == 0 {
+		return nil, nil, fmt.Errorf("missing presence byte")
+	}
+	if rest[0] == 0 {
+		return nil, rest[1:], nil
+	}
+	if len(rest) < 33 {
+		return nil, nil, fmt.Errorf("truncated id")
+	}
+	return rest[1:33], rest[33:], nil
+}
+
+// decodeTags decodes the avro array of {name: bytes, value: bytes} records.
+func decodeTags(encoded []byte) ([]Tag, error) {
+	tags := []Tag{}
+	if len(encoded) == 0 {
+		return tags, nil
+	}
+
+	rest := encoded
+	readLong := func() (int, error) {
+		value, n := binary.Uvarint(rest)
+		if n <= 0 {
+			return 0, fmt.Errorf("invalid avro long")
+		}
+		rest = rest[n:]
+		return int(int64(value>>1) ^ -int64(value&1)), nil
+	}
+	readBytes := func() (string, error) {
+		length, err := readLong()
+		if err != nil {
+			return "", err
+		}
+		if length < 0 || length > len(rest) {
+			return "", fmt.Errorf("invalid avro bytes length %d", length)
+		}
+		value := string(rest[:length])
+		rest = rest[length:]
+		return value, nil
+	}
+
+	for {
+		count, err := readLong()
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			break
+		}
+		for i := 0; i < count; i++ {
+			name, err := readBytes()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readBytes()
+			if err != nil {
+				return nil, err
+			}
+			tags = append(tags, Tag{Name: name, Value: value})
+		}
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%d bytes after the tags", len(rest))
+	}
+
+	return tags, nil
+}
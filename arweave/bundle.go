@@ -0,0 +1,42 @@
+package arweave
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+)
+
+// BundleTags mark a transaction as carrying an ANS-104 bundle.
+var BundleTags = []Tag{
+	{Name: "Bundle-Format", Value: "binary"},
+	{Name: "Bundle-Version", Value: "2.0.0"},
+}
+
+// NewBundle serializes data items into an ANS-104 binary bundle.
+func NewBundle(items []*DataItem) ([]byte, error) {
+	size := 32
+	for _, item := range items {
+		size += 64 + len(item.Raw)
+	}
+
+	bundle := make([]byte, 32, size)
+	binary.LittleEndian.PutUint64(bundle, uint64(len(items)))
+
+	for _, item := range items {
+		id, err := base64.RawURLEncoding.DecodeString(item.Id)
+		if err != nil || len(id) != 32 {
+			return nil, fmt.Errorf("invalid data item id %q", item.Id)
+		}
+
+		header := make([]byte, 32)
+		binary.LittleEndian.PutUint64(header, uint64(len(item.Raw)))
+		bundle = append(bundle, header...)
+		bundle = append(bundle, id...)
+	}
+
+	for _, item := range items {
+		bundle = append(bundle, item.Raw...)
+	}
+
+	return bundle, nil
+}
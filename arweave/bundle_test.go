@@ -0,0 +1,66 @@
+package arweave
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+)
+
+func TestNewBundle(t *testing.T) {
+	wallet := newTestWallet(t)
+
+	items := []*DataItem{}
+	for _, data := range []string{"first", "second item"} {
+		item, err := NewDataItem(wallet, "", []Tag{{Name: "Content-Type", Value: "text/plain"}}, []byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+
+	bundle, err := NewBundle(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if binary.LittleEndian.Uint64(bundle) != uint64(len(items)) || !bytes.Equal(bundle[8:32], make([]byte, 24)) {
+		t.Errorf("expected a 32 byte item count of %d, got %x", len(items), bundle[:32])
+	}
+
+	offset := 32 + 64*len(items)
+	for i, item := range items {
+		header := bundle[32+64*i : 32+64*(i+1)]
+		size := int(binary.LittleEndian.Uint64(header))
+		if size != len(item.Raw) || !bytes.Equal(header[8:32], make([]byte, 24)) {
+			t.Errorf("expected a 32 byte size of %d for item %d, got %x", len(item.Raw), i, header[:32])
+		}
+		if base64.RawURLEncoding.EncodeToString(header[32:]) != item.Id {
+			t.Errorf("expected id %s for item %d, got %x", item.Id, i, header[32:])
+		}
+
+		if !bytes.Equal(bundle[offset:offset+size], item.Raw) {
+			t.Errorf("expected item %d to follow the headers", i)
+		}
+		offset += size
+	}
+
+	if offset != len(bundle) {
+		t.Errorf("expected a bundle of %d bytes, got %d", offset, len(bundle))
+	}
+
+	_, err = NewBundle([]*DataItem{{Id: "invalid"}})
+	if err == nil {
+		t.Error("expected an invalid data item id to be rejected")
+	}
+}
+
+func TestNewEmptyBundle(t *testing.T) {
+	bundle, err := NewBundle(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bundle, make([]byte, 32)) {
+		t.Errorf("expected an empty bundle to be a zero item count, got %x", bundle)
+	}
+}
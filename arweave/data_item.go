@@ -0,0 +1,115 @@
+package arweave
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	SignatureTypeArweave = 1
+
+	arweaveSignatureLength = 512
+	arweaveOwnerLength     = 512
+)
+
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// DataItem is a signed ANS-104 data item.
+type DataItem struct {
+	Id    string
+	Owner []byte
+	Raw   []byte
+}
+
+// NewDataItem builds and signs an ANS-104 data item. target is an optional
+// base64url encoded transaction or process id.
+func NewDataItem(wallet *Wallet, target string, tags []Tag, data []byte) (*DataItem, error) {
+	owner := wallet.Owner()
+	if len(owner) != arweaveOwnerLength {
+		return nil, fmt.Errorf("wallet owner of %d bytes is not supported", len(owner))
+	}
+
+	targetBytes := []byte{}
+	if target != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(target)
+		if err != nil || len(decoded) != 32 {
+			return nil, fmt.Errorf("invalid data item target %q", target)
+		}
+		targetBytes = decoded
+	}
+
+	anchor := make([]byte, 32)
+	_, err := rand.Read(anchor)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate anchor: %w", err)
+	}
+
+	encodedTags := encodeTags(tags)
+
+	message := DeepHash([][]byte{
+		[]byte("dataitem"),
+		[]byte("1"),
+		[]byte(fmt.Sprint(SignatureTypeArweave)),
+		owner,
+		targetBytes,
+		anchor,
+		encodedTags,
+		data,
+	})
+
+	signature, err := wallet.Sign(message)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign data item: %w", err)
+	}
+
+	raw := binary.LittleEndian.AppendUint16(nil, SignatureTypeArweave)
+	raw = append(raw, signature...)
+	raw = append(raw, owner...)
+	if len(targetBytes) > 0 {
+		raw = append(raw, 1)
+		raw = append(raw, targetBytes...)
+	} else {
+		raw = append(raw, 0)
+	}
+	raw = append(raw, 1)
+	raw = append(raw, anchor...)
+	raw = binary.LittleEndian.AppendUint64(raw, uint64(len(tags)))
+	raw = binary.LittleEndian.AppendUint64(raw, uint64(len(encodedTags)))
+	raw = append(raw, encodedTags...)
+	raw = append(raw, data...)
+
+	id := sha256.Sum256(signature)
+
+	return &DataItem{
+		Id:    base64.RawURLEncoding.EncodeToString(id[:]),
+		Owner: owner,
+		Raw:   raw,
+	}, nil
+}
+
+// encodeTags serializes tags as the avro array of {name: bytes, value: bytes} ANS-104 expects.
+func encodeTags(tags []Tag) []byte {
+	if len(tags) == 0 {
+		return []byte{}
+	}
+
+	encoded := avroLong(nil, int64(len(tags)))
+	for _, tag := range tags {
+		encoded = avroLong(encoded, int64(len(tag.Name)))
+		encoded = append(encoded, tag.Name...)
+		encoded = avroLong(encoded, int64(len(tag.Value)))
+		encoded = append(encoded, tag.Value...)
+	}
+
+	return avroLong(encoded, 0)
+}
+
+func avroLong(buf []byte, value int64) []byte {
+	return binary.AppendUvarint(buf, uint64((value<<1)^(value>>63)))
+}
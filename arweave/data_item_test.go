@@ -0,0 +1,109 @@
+package arweave
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/the-singularity-labs/cornelius/arweave/arweavetest"
+)
+
+func TestEncodeTags(t *testing.T) {
+	if len(encodeTags(nil)) != 0 {
+		t.Error("expected no tags to encode to nothing")
+	}
+
+	// an avro block of two records, zigzag encoded lengths, and the closing
+	// empty block
+	expected := []byte{0x04, 0x02, 'a', 0x04, 'b', 'c', 0x08, 'n', 'a', 'm', 'e', 0x00, 0x00}
+	encoded := encodeTags([]Tag{{Name: "a", Value: "bc"}, {Name: "name", Value: ""}})
+	if !bytes.Equal(encoded, expected) {
+		t.Errorf("expected tags %x, got %x", expected, encoded)
+	}
+
+	long := encodeTags([]Tag{{Name: string(bytes.Repeat([]byte("x"), 64)), Value: "v"}})
+	if !bytes.Equal(long[:3], []byte{0x02, 0x80, 0x01}) {
+		t.Errorf("expected a two byte varint for a 64 byte name, got %x", long[:3])
+	}
+}
+
+func TestNewDataItem(t *testing.T) {
+	wallet := newTestWallet(t)
+	target := base64.RawURLEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	tags := []Tag{{Name: "Action", Value: "Set-Record"}, {Name: "TTL-Seconds", Value: "3600"}}
+	data := []byte("1234")
+
+	dataItem, err := NewDataItem(wallet, target, tags, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := arweavetest.ParseDataItem(dataItem.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.SignatureType != SignatureTypeArweave {
+		t.Errorf("expected signature type %d, got %d", SignatureTypeArweave, item.SignatureType)
+	}
+	if !bytes.Equal(item.Owner, wallet.Owner()) || !bytes.Equal(dataItem.Owner, wallet.Owner()) {
+		t.Error("expected the wallet to own the data item")
+	}
+	if base64.RawURLEncoding.EncodeToString(item.Target) != target {
+		t.Errorf("expected target %s, got %x", target, item.Target)
+	}
+	if len(item.Anchor) != 32 {
+		t.Errorf("expected an anchor of 32 bytes, got %d", len(item.Anchor))
+	}
+	if item.TagCount != len(tags) || !bytes.Equal(item.RawTags, encodeTags(tags)) {
+		t.Errorf("expected %d encoded tags, got %d: %x", len(tags), item.TagCount, item.RawTags)
+	}
+	if tagMap := item.TagMap(); len(tagMap) != 2 || tagMap["Action"] != "Set-Record" || tagMap["TTL-Seconds"] != "3600" {
+		t.Errorf("expected the tags to decode, got %v", tagMap)
+	}
+	if !bytes.Equal(item.Data, data) {
+		t.Errorf("expected data %q, got %q", data, item.Data)
+	}
+
+	id := sha256.Sum256(item.Signature)
+	if dataItem.Id != base64.RawURLEncoding.EncodeToString(id[:]) {
+		t.Errorf("expected the id to be the hash of the signature, got %s", dataItem.Id)
+	}
+
+	arweavetest.VerifySignature(t, item.Owner, DeepHash([][]byte{
+		[]byte("dataitem"),
+		[]byte("1"),
+		[]byte("1"),
+		item.Owner,
+		item.Target,
+		item.Anchor,
+		item.RawTags,
+		item.Data,
+	}), item.Signature)
+}
+
+func TestNewDataItemWithoutTarget(t *testing.T) {
+	wallet := newTestWallet(t)
+
+	dataItem, err := NewDataItem(wallet, "", nil, []byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := arweavetest.ParseDataItem(dataItem.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.SignatureType != SignatureTypeArweave || !bytes.Equal(item.Owner, wallet.Owner()) || len(item.Anchor) != 32 {
+		t.Errorf("unexpected data item header %+v", item)
+	}
+	if item.Target != nil || item.TagCount != 0 || len(item.RawTags) != 0 || len(item.Data) != 0 {
+		t.Errorf("expected no target, tags or data, got %+v", item)
+	}
+
+	_, err = NewDataItem(wallet, "not-a-process-id", nil, nil)
+	if err == nil {
+		t.Error("expected an invalid target to be rejected")
+	}
+}
@@ -0,0 +1,37 @@
+package arweave
+
+import (
+	"crypto/sha512"
+	"strconv"
+)
+
+// DeepHash implements the SHA-384 based deep hash over nested byte chunks
+// that Arweave transactions and ANS-104 data items are signed with. Chunks
+// are either []byte or [][]byte / []any of chunks.
+func DeepHash(chunk any) []byte {
+	switch value := chunk.(type) {
+	case []byte:
+		tag := append([]byte("blob"), []byte(strconv.Itoa(len(value)))...)
+		return sha384(append(sha384(tag), sha384(value)...))
+	case [][]byte:
+		list := make([]any, len(value))
+		for i, item := range value {
+			list[i] = item
+		}
+		return DeepHash(list)
+	case []any:
+		tag := append([]byte("list"), []byte(strconv.Itoa(len(value)))...)
+		acc := sha384(tag)
+		for _, item := range value {
+			acc = sha384(append(acc, DeepHash(item)...))
+		}
+		return acc
+	default:
+		panic("deep hash chunks must be []byte or lists of chunks")
+	}
+}
+
+func sha384(data []byte) []byte {
+	digest := sha512.Sum384(data)
+	return digest[:]
+}
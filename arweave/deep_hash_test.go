@@ -0,0 +1,31 @@
+package arweave
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestDeepHash(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		chunk any
+		hash  string
+	}{
+		{"empty blob", []byte{}, "fbf00cc444f5fea9dc3bedf62a13fba8ae87e7445fc910567a23bec4eb82fadb1143c433069314d8362983dc3c2e4a38"},
+		{"blob", []byte("hello"), "33ab2407a6c328c0bc1bbe5971f49af5c1908985f83c3d2bd89a9e221dd8b068dc61ce968ba3f9ab12d5361ba3944382"},
+		{"empty list", []any{}, "a69e7d37fdc7f040a9ec16aae84de24fab4a653dac4de0bd247e36bab9fe45d9289c5a04a893c95285812f5cefc9707a"},
+		{"nested list", []any{[]byte("a"), [][]byte{[]byte("b"), []byte("c")}, []byte{}}, "041610a481af67a00b33e0e2197bf20e92747db97a47197e2181648ea1f571d7d11537859258d953eebf88a170abccb0"},
+	} {
+		hash := hex.EncodeToString(DeepHash(test.chunk))
+		if hash != test.hash {
+			t.Errorf("expected deep hash of %s to be %s, got %s", test.name, test.hash, hash)
+		}
+	}
+}
+
+func TestDeepHashByteLists(t *testing.T) {
+	chunks := [][]byte{[]byte("a"), []byte("b")}
+	if hex.EncodeToString(DeepHash(chunks)) != hex.EncodeToString(DeepHash([]any{chunks[0], chunks[1]})) {
+		t.Error("expected [][]byte to hash like the equivalent []any")
+	}
+}
@@ -0,0 +1,289 @@
+package arweave
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Gateway talks to the HTTP API of an Arweave gateway. Reads go to url,
+// transactions and chunks are posted to uploadURL and GraphQL queries are
+// sent to graphqlURL. Data downloads are only bounded by the read timeout
+// until the response headers arrive, so large files can be streamed.
+type Gateway struct {
+	url          string
+	uploadURL    string
+	graphqlURL   string
+	httpClient   *http.Client
+	uploadClient *http.Client
+	dataClient   *http.Client
+}
+
+func NewGateway(url, uploadURL, graphqlURL string, timeout, uploadTimeout time.Duration) *Gateway {
+	dataTransport := http.DefaultTransport.(*http.Transport).Clone()
+	dataTransport.ResponseHeaderTimeout = timeout
+
+	return &Gateway{
+		url:          strings.TrimSuffix(url, "/"),
+		uploadURL:    strings.TrimSuffix(uploadURL, "/"),
+		graphqlURL:   graphqlURL,
+		httpClient:   &http.Client{Timeout: timeout},
+		uploadClient: &http.Client{Timeout: uploadTimeout},
+		dataClient:   &http.Client{Transport: dataTransport},
+	}
+}
+
+// Price returns the winston reward for storing the given number of bytes.
+func (gateway *Gateway) Price(ctx context.Context, bytes int) (string, error) {
+	price, err := gateway.getText(ctx, fmt.Sprintf("%s/price/%d", gateway.url, bytes))
+	if err != nil {
+		return "", fmt.Errorf("unable to get price: %w", err)
+	}
+
+	return price, nil
+}
+
+// TxAnchor returns a recent block to anchor new transactions to.
+func (gateway *Gateway) TxAnchor(ctx context.Context) (string, error) {
+	anchor, err := gateway.getText(ctx, gateway.url+"/tx_anchor")
+	if err != nil {
+		return "", fmt.Errorf("unable to get transaction anchor: %w", err)
+	}
+
+	return anchor, nil
+}
+
+// PostTransaction posts the transaction header followed by its data chunks.
+// Calling it again after a failure resumes with the first chunk that was not
+// accepted, so the header, which the wallet pays for, is only posted once.
+func (gateway *Gateway) PostTransaction(ctx context.Context, tx *Transaction) error {
+	if !tx.headerPosted {
+		err := gateway.postJson(ctx, gateway.uploadURL+"/tx", tx)
+		if err != nil {
+			return fmt.Errorf("unable to post transaction %q: %w", tx.Id, err)
+		}
+		tx.headerPosted = true
+	}
+
+	for ; tx.chunksPosted < len(tx.chunks); tx.chunksPosted++ {
+		chunk := tx.chunks[tx.chunksPosted]
+		err := gateway.postJson(ctx, gateway.uploadURL+"/chunk", map[string]string{
+			"data_root": tx.DataRoot,
+			"data_size": tx.DataSize,
+			"data_path": base64.RawURLEncoding.EncodeToString(chunk.Proof),
+			"offset":    strconv.Itoa(chunk.MaxByteRange - 1),
+			"chunk":     base64.RawURLEncoding.EncodeToString(tx.data[chunk.MinByteRange:chunk.MaxByteRange]),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to post chunk at offset %d of transaction %q: %w", chunk.MinByteRange, tx.Id, err)
+		}
+	}
+
+	return nil
+}
+
+func (gateway *Gateway) getText(ctx context.Context, url string) (string, error) {
+	body, err := gateway.do(ctx, gateway.httpClient, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+func (gateway *Gateway) postJson(ctx context.Context, url string, value any) error {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("unable to encode request: %w", err)
+	}
+
+	_, err = gateway.do(ctx, gateway.uploadClient, http.MethodPost, url, payload)
+	return err
+}
+
+func (gateway *Gateway) do(ctx context.Context, client *http.Client, method, url string, payload []byte) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build gateway request: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach gateway: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read gateway response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(respBody))}
+	}
+
+	return respBody, nil
+}
+
+// StatusError is returned when the gateway answers with a non 2xx status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("gateway returned %d %s: %s", err.StatusCode, http.StatusText(err.StatusCode), err.Body)
+}
+
+// Data streams the data of a transaction or data item. The caller has to
+// close the returned reader.
+func (gateway *Gateway) Data(ctx context.Context, id string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s", gateway.url, id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build gateway request: %w", err)
+	}
+
+	resp, err := gateway.dataClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach gateway: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+
+	return resp.Body, nil
+}
+
+// TxStatus is the confirmation status of a transaction or data item. A
+// found transaction without confirmations is pending.
+type TxStatus struct {
+	Found         bool
+	Confirmations int
+}
+
+// Status returns the status of a transaction, falling back to GraphQL for
+// ids the gateway has no transaction status for, such as bundled data items.
+func (gateway *Gateway) Status(ctx context.Context, id string) (TxStatus, error) {
+	body, err := gateway.do(ctx, gateway.httpClient, http.MethodGet, fmt.Sprintf("%s/tx/%s/status", gateway.url, id), nil)
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return gateway.graphqlStatus(ctx, id)
+	} else if err != nil {
+		return TxStatus{}, fmt.Errorf("unable to get status of %q: %w", id, err)
+	}
+
+	status := struct {
+		NumberOfConfirmations int `json:"number_of_confirmations"`
+	}{}
+	err = json.Unmarshal(body, &status)
+	if err != nil {
+		// pending transactions are answered with a plain "Pending"
+		return TxStatus{Found: true}, nil
+	}
+
+	return TxStatus{Found: true, Confirmations: status.NumberOfConfirmations}, nil
+}
+
+// Height returns the current block height of the network.
+func (gateway *Gateway) Height(ctx context.Context) (int, error) {
+	body, err := gateway.do(ctx, gateway.httpClient, http.MethodGet, gateway.url+"/info", nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get network info: %w", err)
+	}
+
+	info := struct {
+		Height int `json:"height"`
+	}{}
+	err = json.Unmarshal(body, &info)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse network info: %w", err)
+	}
+
+	return info.Height, nil
+}
+
+const graphqlStatusQuery = `query($ids: [ID!]) { transactions(ids: $ids) { edges { node { id block { height } } } } }`
+
+func (gateway *Gateway) graphqlStatus(ctx context.Context, id string) (TxStatus, error) {
+	payload, err := json.Marshal(map[string]any{
+		"query":     graphqlStatusQuery,
+		"variables": map[string]any{"ids": []string{id}},
+	})
+	if err != nil {
+		return TxStatus{}, fmt.Errorf("unable to encode graphql query: %w", err)
+	}
+
+	body, err := gateway.do(ctx, gateway.httpClient, http.MethodPost, gateway.graphqlURL, payload)
+	if err != nil {
+		return TxStatus{}, fmt.Errorf("unable to query status of %q: %w", id, err)
+	}
+
+	result := struct {
+		Data struct {
+			Transactions struct {
+				Edges []struct {
+					Node struct {
+						Id    string `json:"id"`
+						Block *struct {
+							Height int `json:"height"`
+						} `json:"block"`
+					} `json:"node"`
+				} `json:"edges"`
+			} `json:"transactions"`
+		} `json:"data"`
+	}{}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return TxStatus{}, fmt.Errorf("unable to parse graphql response: %w", err)
+	}
+
+	edges := result.Data.Transactions.Edges
+	if len(edges) == 0 {
+		return TxStatus{}, nil
+	} else if edges[0].Node.Block == nil {
+		return TxStatus{Found: true}, nil
+	}
+
+	height, err := gateway.Height(ctx)
+	if err != nil {
+		return TxStatus{}, err
+	}
+
+	return TxStatus{Found: true, Confirmations: max(height-edges[0].Node.Block.Height+1, 1)}, nil
+}
+
+// Balance returns the winston balance of a wallet address.
+func (gateway *Gateway) Balance(ctx context.Context, address string) (*big.Int, error) {
+	body, err := gateway.getText(ctx, fmt.Sprintf("%s/wallet/%s/balance", gateway.url, address))
+	if err != nil {
+		return nil, fmt.Errorf("unable to get balance of %q: %w", address, err)
+	}
+
+	balance, ok := new(big.Int).SetString(body, 10)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a valid balance", body)
+	}
+
+	return balance, nil
+}
@@ -0,0 +1,63 @@
+package arweave
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGatewayStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tx/settled/status":
+			w.Write([]byte(`{"block_height": 90, "number_of_confirmations": 5}`))
+		case "/tx/pending/status":
+			w.Write([]byte("Pending"))
+		case "/info":
+			w.Write([]byte(`{"height": 109}`))
+		case "/graphql":
+			query := struct {
+				Variables struct {
+					Ids []string `json:"ids"`
+				} `json:"variables"`
+			}{}
+			err := json.NewDecoder(r.Body).Decode(&query)
+			if err != nil || len(query.Variables.Ids) != 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			node := ""
+			switch id := query.Variables.Ids[0]; id {
+			case "item":
+				node = fmt.Sprintf(`{"node": {"id": %q, "block": {"height": 100}}}`, id)
+			case "unmined-item":
+				node = fmt.Sprintf(`{"node": {"id": %q, "block": null}}`, id)
+			}
+			fmt.Fprintf(w, `{"data": {"transactions": {"edges": [%s]}}}`, node)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	gateway := NewGateway(server.URL, server.URL, server.URL+"/graphql", time.Minute, time.Minute)
+	for id, expected := range map[string]TxStatus{
+		"settled":      {Found: true, Confirmations: 5},
+		"pending":      {Found: true},
+		"item":         {Found: true, Confirmations: 10},
+		"unmined-item": {Found: true},
+		"unknown":      {},
+	} {
+		status, err := gateway.Status(context.Background(), id)
+		if err != nil {
+			t.Errorf("unable to get status of %s: %v", id, err)
+		} else if status != expected {
+			t.Errorf("expected status %+v of %s, got %+v", expected, id, status)
+		}
+	}
+}
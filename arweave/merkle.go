@@ -0,0 +1,129 @@
+package arweave
+
+import (
+	"crypto/sha256"
+	"math/big"
+)
+
+const (
+	MaxChunkSize = 256 * 1024
+	MinChunkSize = 32 * 1024
+
+	noteSize = 32
+)
+
+// Chunk is a slice of transaction data along with its merkle proof.
+type Chunk struct {
+	MinByteRange int
+	MaxByteRange int
+	DataHash     []byte
+	Proof        []byte
+}
+
+type merkleNode struct {
+	id           []byte
+	dataHash     []byte
+	byteRange    int
+	maxByteRange int
+	left, right  *merkleNode
+}
+
+// chunkData splits data into chunks the way the reference clients do,
+// rebalancing the last two chunks so neither is smaller than MinChunkSize.
+func chunkData(data []byte) []Chunk {
+	chunks := []Chunk{}
+	rest := data
+	cursor := 0
+
+	for len(rest) >= MaxChunkSize {
+		chunkSize := MaxChunkSize
+		nextChunkSize := len(rest) - MaxChunkSize
+		if nextChunkSize > 0 && nextChunkSize < MinChunkSize {
+			chunkSize = (len(rest) + 1) / 2
+		}
+
+		digest := sha256.Sum256(rest[:chunkSize])
+		chunks = append(chunks, Chunk{MinByteRange: cursor, MaxByteRange: cursor + chunkSize, DataHash: digest[:]})
+		cursor += chunkSize
+		rest = rest[chunkSize:]
+	}
+
+	digest := sha256.Sum256(rest)
+	return append(chunks, Chunk{MinByteRange: cursor, MaxByteRange: cursor + len(rest), DataHash: digest[:]})
+}
+
+// merkleize computes the data root of data and fills in the proof of every chunk.
+func merkleize(data []byte) ([]byte, []Chunk) {
+	chunks := chunkData(data)
+
+	nodes := make([]*merkleNode, len(chunks))
+	for i, chunk := range chunks {
+		nodes[i] = &merkleNode{
+			id:           hashAll(hashAll(chunk.DataHash), hashAll(note(chunk.MaxByteRange))),
+			dataHash:     chunk.DataHash,
+			maxByteRange: chunk.MaxByteRange,
+		}
+	}
+
+	for len(nodes) > 1 {
+		parents := []*merkleNode{}
+		for i := 0; i < len(nodes); i += 2 {
+			if i+1 == len(nodes) {
+				parents = append(parents, nodes[i])
+				continue
+			}
+
+			left, right := nodes[i], nodes[i+1]
+			parents = append(parents, &merkleNode{
+				id:           hashAll(hashAll(left.id), hashAll(right.id), hashAll(note(left.maxByteRange))),
+				byteRange:    left.maxByteRange,
+				maxByteRange: right.maxByteRange,
+				left:         left,
+				right:        right,
+			})
+		}
+		nodes = parents
+	}
+
+	root := nodes[0]
+	proofs := [][]byte{}
+	resolveProofs(root, []byte{}, &proofs)
+	for i := range chunks {
+		chunks[i].Proof = proofs[i]
+	}
+
+	// like the reference clients, data of a multiple of MaxChunkSize keeps
+	// its empty last leaf in the tree but never uploads it
+	if last := chunks[len(chunks)-1]; len(chunks) > 1 && last.MaxByteRange == last.MinByteRange {
+		chunks = chunks[:len(chunks)-1]
+	}
+
+	return root.id, chunks
+}
+
+func resolveProofs(node *merkleNode, proof []byte, proofs *[][]byte) {
+	if node.left == nil {
+		leafProof := append(append([]byte{}, proof...), node.dataHash...)
+		*proofs = append(*proofs, append(leafProof, note(node.maxByteRange)...))
+		return
+	}
+
+	branchProof := append(append([]byte{}, proof...), node.left.id...)
+	branchProof = append(branchProof, node.right.id...)
+	branchProof = append(branchProof, note(node.byteRange)...)
+
+	resolveProofs(node.left, branchProof, proofs)
+	resolveProofs(node.right, branchProof, proofs)
+}
+
+func note(value int) []byte {
+	return new(big.Int).SetInt64(int64(value)).FillBytes(make([]byte, noteSize))
+}
+
+func hashAll(parts ...[]byte) []byte {
+	digest := sha256.New()
+	for _, part := range parts {
+		digest.Write(part)
+	}
+	return digest.Sum(nil)
+}
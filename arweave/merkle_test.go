@@ -0,0 +1,81 @@
+package arweave
+
+import (
+	"bytes"
+	"encoding/base64"
+	"math/big"
+	"testing"
+)
+
+// patternData returns size bytes of a repeating, non chunk aligned pattern.
+func patternData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+// validatePath walks a chunk proof from the data root like gateways do and
+// returns the byte range of the chunk it proves.
+func validatePath(id []byte, dest, leftBound, rightBound int, path []byte) (int, int, bool) {
+	if len(path) == 2*noteSize {
+		dataHash, endOffset := path[:noteSize], path[noteSize:]
+		if !bytes.Equal(id, hashAll(hashAll(dataHash), hashAll(endOffset))) {
+			return 0, 0, false
+		}
+		return leftBound, rightBound, true
+	}
+	if len(path) < 3*noteSize {
+		return 0, 0, false
+	}
+
+	left, right, offsetNote := path[:noteSize], path[noteSize:2*noteSize], path[2*noteSize:3*noteSize]
+	if !bytes.Equal(id, hashAll(hashAll(left), hashAll(right), hashAll(offsetNote))) {
+		return 0, 0, false
+	}
+
+	offset := int(new(big.Int).SetBytes(offsetNote).Int64())
+	if dest < offset {
+		return validatePath(left, dest, leftBound, min(rightBound, offset), path[3*noteSize:])
+	}
+	return validatePath(right, dest, max(leftBound, offset), rightBound, path[3*noteSize:])
+}
+
+func TestMerkleize(t *testing.T) {
+	for _, test := range []struct {
+		size     int
+		dataRoot string
+		ranges   [][2]int
+	}{
+		{1, "Ht_yZhXGBDUZfLv4OD6we7FkrjcpDpGPwQZEZgEJVfk", [][2]int{{0, 1}}},
+		// the empty last leaf stays in the tree but is not uploaded
+		{MaxChunkSize, "gty7KB2baLFp7OGxuV2wBeX3NippS1tNVlMOZryIq5o", [][2]int{{0, MaxChunkSize}}},
+		// a last chunk below MinChunkSize is balanced with the one before
+		{MaxChunkSize + 1000, "bFtxR6l6BRJt4Y7utAH1mGDg4Ps969jw17_NfjGzB_Q", [][2]int{{0, 131572}, {131572, 263144}}},
+		{3*MaxChunkSize + 5, "TCaxvDqCF5NBvUK0Ca944NTZvSggR2OTUBQBXSsobhA", [][2]int{{0, 262144}, {262144, 524288}, {524288, 655363}, {655363, 786437}}},
+	} {
+		data := patternData(test.size)
+		dataRoot, chunks := merkleize(data)
+
+		if base64.RawURLEncoding.EncodeToString(dataRoot) != test.dataRoot {
+			t.Errorf("expected data root %s for %d bytes, got %s", test.dataRoot, test.size, base64.RawURLEncoding.EncodeToString(dataRoot))
+		}
+
+		if len(chunks) != len(test.ranges) {
+			t.Fatalf("expected %d chunks for %d bytes, got %d", len(test.ranges), test.size, len(chunks))
+		}
+		for i, chunk := range chunks {
+			if chunk.MinByteRange != test.ranges[i][0] || chunk.MaxByteRange != test.ranges[i][1] {
+				t.Errorf("expected chunk %d of %d bytes to span %v, got [%d %d]", i, test.size, test.ranges[i], chunk.MinByteRange, chunk.MaxByteRange)
+			}
+
+			minByteRange, maxByteRange, valid := validatePath(dataRoot, chunk.MinByteRange, 0, test.size, chunk.Proof)
+			if !valid {
+				t.Errorf("invalid proof for chunk %d of %d bytes", i, test.size)
+			} else if minByteRange != chunk.MinByteRange || maxByteRange != chunk.MaxByteRange {
+				t.Errorf("expected the proof of chunk %d of %d bytes to span %v, got [%d %d]", i, test.size, test.ranges[i], minByteRange, maxByteRange)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package arweave
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+)
+
+// Transaction is a signed format 2 Arweave transaction. Its data is uploaded
+// in chunks after the header has been posted.
+type Transaction struct {
+	Format    int              `json:"format"`
+	Id        string           `json:"id"`
+	LastTx    string           `json:"last_tx"`
+	Owner     string           `json:"owner"`
+	Tags      []TransactionTag `json:"tags"`
+	Target    string           `json:"target"`
+	Quantity  string           `json:"quantity"`
+	Data      string           `json:"data"`
+	DataSize  string           `json:"data_size"`
+	DataRoot  string           `json:"data_root"`
+	Reward    string           `json:"reward"`
+	Signature string           `json:"signature"`
+
+	data   []byte
+	chunks []Chunk
+
+	// progress of PostTransaction, so a failed upload can be resumed
+	headerPosted bool
+	chunksPosted int
+}
+
+// TransactionTag is a tag with base64url encoded name and value.
+type TransactionTag struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// NewTransaction builds and signs a data transaction. lastTx is the anchor
+// returned by the gateway and reward the winston price of the data.
+func NewTransaction(wallet *Wallet, tags []Tag, data []byte, lastTx, reward string) (*Transaction, error) {
+	tx := &Transaction{
+		Format:   2,
+		LastTx:   lastTx,
+		Owner:    base64.RawURLEncoding.EncodeToString(wallet.Owner()),
+		Tags:     []TransactionTag{},
+		Quantity: "0",
+		DataSize: strconv.Itoa(len(data)),
+		Reward:   reward,
+		data:     data,
+	}
+
+	tagChunks := []any{}
+	for _, tag := range tags {
+		tx.Tags = append(tx.Tags, TransactionTag{
+			Name:  base64.RawURLEncoding.EncodeToString([]byte(tag.Name)),
+			Value: base64.RawURLEncoding.EncodeToString([]byte(tag.Value)),
+		})
+		tagChunks = append(tagChunks, [][]byte{[]byte(tag.Name), []byte(tag.Value)})
+	}
+
+	dataRoot := []byte{}
+	if len(data) > 0 {
+		dataRoot, tx.chunks = merkleize(data)
+		tx.DataRoot = base64.RawURLEncoding.EncodeToString(dataRoot)
+	}
+
+	lastTxBytes, err := base64.RawURLEncoding.DecodeString(lastTx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction anchor %q: %w", lastTx, err)
+	}
+
+	message := DeepHash([]any{
+		[]byte("2"),
+		wallet.Owner(),
+		[]byte{},
+		[]byte(tx.Quantity),
+		[]byte(tx.Reward),
+		lastTxBytes,
+		tagChunks,
+		[]byte(tx.DataSize),
+		dataRoot,
+	})
+
+	signature, err := wallet.Sign(message)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign transaction: %w", err)
+	}
+
+	id := sha256.Sum256(signature)
+	tx.Signature = base64.RawURLEncoding.EncodeToString(signature)
+	tx.Id = base64.RawURLEncoding.EncodeToString(id[:])
+
+	return tx, nil
+}
@@ -0,0 +1,126 @@
+package arweave
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/the-singularity-labs/cornelius/arweave/arweavetest"
+)
+
+func TestNewTransaction(t *testing.T) {
+	wallet := newTestWallet(t)
+	data := patternData(MaxChunkSize + 1000)
+	anchor := base64.RawURLEncoding.EncodeToString(bytes.Repeat([]byte{3}, 48))
+
+	tx, err := NewTransaction(wallet, BundleTags, data, anchor, "12345")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tx.Format != 2 || tx.DataSize != strconv.Itoa(len(data)) || tx.Reward != "12345" || tx.Quantity != "0" {
+		t.Errorf("unexpected transaction header %+v", tx)
+	}
+	if tx.Tags[0].Name != "QnVuZGxlLUZvcm1hdA" || tx.Tags[0].Value != "YmluYXJ5" {
+		t.Errorf("expected base64url encoded tags, got %+v", tx.Tags[0])
+	}
+
+	dataRoot, _ := merkleize(data)
+	if tx.DataRoot != base64.RawURLEncoding.EncodeToString(dataRoot) {
+		t.Errorf("expected data root %x, got %s", dataRoot, tx.DataRoot)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(tx.Signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastTx, _ := base64.RawURLEncoding.DecodeString(anchor)
+	arweavetest.VerifySignature(t, wallet.Owner(), DeepHash([]any{
+		[]byte("2"),
+		wallet.Owner(),
+		[]byte{},
+		[]byte("0"),
+		[]byte("12345"),
+		lastTx,
+		[]any{
+			[][]byte{[]byte("Bundle-Format"), []byte("binary")},
+			[][]byte{[]byte("Bundle-Version"), []byte("2.0.0")},
+		},
+		[]byte(strconv.Itoa(len(data))),
+		dataRoot,
+	}), signature)
+
+	_, err = NewTransaction(wallet, nil, data, "not base64!", "1")
+	if err == nil {
+		t.Error("expected an invalid anchor to be rejected")
+	}
+}
+
+func TestPostTransactionResumes(t *testing.T) {
+	headers := 0
+	chunks := map[string]string{}
+	failChunks := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tx":
+			headers++
+		case "/chunk":
+			chunk := map[string]string{}
+			err := json.NewDecoder(r.Body).Decode(&chunk)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if failChunks && len(chunks) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			chunks[chunk["offset"]] = chunk["chunk"]
+		}
+	}))
+	defer server.Close()
+
+	wallet := newTestWallet(t)
+	data := patternData(3*MaxChunkSize + 5)
+	tx, err := NewTransaction(wallet, nil, data, "", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gateway := NewGateway(server.URL, server.URL, server.URL+"/graphql", time.Minute, time.Minute)
+	err = gateway.PostTransaction(context.Background(), tx)
+	if err == nil {
+		t.Fatal("expected the second chunk to fail")
+	}
+
+	failChunks = false
+	err = gateway.PostTransaction(context.Background(), tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if headers != 1 {
+		t.Errorf("expected the header to be posted once, got %d", headers)
+	}
+	if len(chunks) != 4 {
+		t.Fatalf("expected 4 chunks, got %d", len(chunks))
+	}
+
+	uploaded := []byte{}
+	for _, offset := range []int{262143, 524287, 655362, 786436} {
+		chunk, err := base64.RawURLEncoding.DecodeString(chunks[strconv.Itoa(offset)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploaded = append(uploaded, chunk...)
+	}
+	if !bytes.Equal(uploaded, data) {
+		t.Error("expected the chunks to add up to the data")
+	}
+}
@@ -0,0 +1,91 @@
+package arweave
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// Wallet is an Arweave RSA wallet loaded from a JWK file.
+type Wallet struct {
+	key *rsa.PrivateKey
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	D   string `json:"d"`
+	P   string `json:"p"`
+	Q   string `json:"q"`
+}
+
+func LoadWallet(path string) (*Wallet, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read wallet %q: %w", path, err)
+	}
+
+	return ParseWallet(contents)
+}
+
+func ParseWallet(contents []byte) (*Wallet, error) {
+	key := jwk{}
+	err := json.Unmarshal(contents, &key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse wallet jwk: %w", err)
+	}
+
+	if key.Kty != "RSA" {
+		return nil, fmt.Errorf("%q is not a supported wallet key type", key.Kty)
+	}
+
+	values := map[string]*big.Int{}
+	for name, encoded := range map[string]string{"n": key.N, "e": key.E, "d": key.D, "p": key.P, "q": key.Q} {
+		decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil || len(decoded) == 0 {
+			return nil, fmt.Errorf("invalid wallet jwk parameter %q", name)
+		}
+		values[name] = new(big.Int).SetBytes(decoded)
+	}
+
+	privateKey := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{
+			N: values["n"],
+			E: int(values["e"].Int64()),
+		},
+		D:      values["d"],
+		Primes: []*big.Int{values["p"], values["q"]},
+	}
+
+	err = privateKey.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallet key: %w", err)
+	}
+	privateKey.Precompute()
+
+	return &Wallet{key: privateKey}, nil
+}
+
+// Owner is the public modulus of the wallet.
+func (wallet *Wallet) Owner() []byte {
+	return wallet.key.N.FillBytes(make([]byte, (wallet.key.N.BitLen()+7)/8))
+}
+
+// Address is the base64url encoded SHA-256 of the owner.
+func (wallet *Wallet) Address() string {
+	digest := sha256.Sum256(wallet.Owner())
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+// Sign signs message with RSA-PSS over SHA-256 like the reference clients.
+func (wallet *Wallet) Sign(message []byte) ([]byte, error) {
+	digest := sha256.Sum256(message)
+	return rsa.SignPSS(rand.Reader, wallet.key, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+}
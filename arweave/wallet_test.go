@@ -0,0 +1,55 @@
+package arweave
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/the-singularity-labs/cornelius/arweave/arweavetest"
+)
+
+func newTestWallet(t *testing.T) *Wallet {
+	t.Helper()
+
+	wallet, err := ParseWallet(arweavetest.WalletJwk(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return wallet
+}
+
+func TestWallet(t *testing.T) {
+	wallet := newTestWallet(t)
+
+	owner := wallet.Owner()
+	if len(owner) != 512 {
+		t.Fatalf("expected an owner of 512 bytes, got %d", len(owner))
+	}
+
+	digest := sha256.Sum256(owner)
+	if wallet.Address() != base64.RawURLEncoding.EncodeToString(digest[:]) {
+		t.Errorf("expected the address to be the hash of the owner, got %s", wallet.Address())
+	}
+
+	message := []byte("message")
+	signature, err := wallet.Sign(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arweavetest.VerifySignature(t, owner, message, signature)
+}
+
+func TestParseWalletErrors(t *testing.T) {
+	for name, contents := range map[string]string{
+		"not json":      "wallet",
+		"not rsa":       `{"kty": "EC"}`,
+		"missing prime": `{"kty": "RSA", "n": "AQAB", "e": "AQAB", "d": "AQAB", "p": "AQAB"}`,
+		"invalid key":   `{"kty": "RSA", "n": "AQAB", "e": "AQAB", "d": "AQAB", "p": "AQAB", "q": "AQAB"}`,
+	} {
+		_, err := ParseWallet([]byte(contents))
+		if err == nil {
+			t.Errorf("expected %s wallet to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/the-singularity-labs/cornelius/log"
+	"github.com/the-singularity-labs/cornelius/sync"
+)
+
+func runCommand(ctx context.Context, logger log.Logger, config sync.Config, args []string) error {
+	switch args[0] {
+	case "keystore":
+		return runKeystoreCommand(logger, config, args[1:])
+	case "dead-letters":
+		return runDeadLettersCommand(logger, config, args[1:])
+	case "retry":
+		return runRetryCommand(logger, config, args[1:])
+	default:
+		return fmt.Errorf("%q is not a valid command", args[0])
+	}
+}
+
+func runKeystoreCommand(logger log.Logger, config sync.Config, args []string) error {
+	if len(args) != 3 || args[0] != "set" {
+		return fmt.Errorf("usage: cornelius -c <config> keystore set <name> <file>")
+	}
+
+	if config.Secrets.Keystore == nil {
+		return fmt.Errorf("no keystore configured under secrets")
+	}
+
+	keystore, err := sync.NewKeystoreSecretProvider(*config.Secrets.Keystore)
+	if err != nil {
+		return fmt.Errorf("unable to open keystore: %w", err)
+	}
+
+	name, secretPath := args[1], args[2]
+	secret, err := os.ReadFile(secretPath)
+	if err != nil {
+		return fmt.Errorf("unable to read secret file %q: %w", secretPath, err)
+	}
+
+	err = keystore.Put(name, secret)
+	if err != nil {
+		return fmt.Errorf("unable to store %q in keystore: %w", name, err)
+	}
+
+	logger.Info("stored secret in keystore", "name", name)
+
+	return nil
+}
+
+func runDeadLettersCommand(logger log.Logger, config sync.Config, args []string) error {
+	if len(args) < 1 || (args[0] == "list" && len(args) > 2) || (args[0] == "clear" && len(args) != 2) || (args[0] != "list" && args[0] != "clear") {
+		return fmt.Errorf("usage: cornelius -c <config> dead-letters list [<pipeline>] | dead-letters clear <pipeline>")
+	}
+
+	state, err := openStateStore(config)
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "clear" {
+		cleared, err := state.ClearDeadLetters(args[1])
+		if err != nil {
+			return fmt.Errorf("unable to clear dead letters of %q: %w", args[1], err)
+		}
+
+		logger.Info("cleared dead letters", "pipeline", args[1], "count", cleared)
+		return nil
+	}
+
+	pipelineNames := state.PipelineNames()
+	if len(args) == 2 {
+		pipelineNames = []string{args[1]}
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "PIPELINE\tKEY\tREASON\tATTEMPTS\tFAILED AT\tLAST ERROR")
+	for _, pipelineName := range pipelineNames {
+		for _, deadLetter := range state.DeadLetters(pipelineName) {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%s\t%s\n", pipelineName, deadLetter.Key, deadLetter.Reason, deadLetter.Attempts, deadLetter.FailedAt.Format(time.RFC3339), deadLetter.LastError)
+		}
+	}
+
+	return writer.Flush()
+}
+
+func runRetryCommand(logger log.Logger, config sync.Config, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: cornelius -c <config> retry <pipeline> <key>")
+	}
+
+	state, err := openStateStore(config)
+	if err != nil {
+		return err
+	}
+
+	pipelineName, key := args[0], args[1]
+	err = state.RetryDeadLetter(pipelineName, key)
+	if err != nil {
+		return fmt.Errorf("unable to retry %q: %w", key, err)
+	}
+
+	logger.Info("object will be retried on the next iteration", "pipeline", pipelineName, "object", key)
+
+	return nil
+}
+
+func openStateStore(config sync.Config) (*sync.StateStore, error) {
+	if config.StatePath == "" {
+		return nil, fmt.Errorf("no state_path configured")
+	}
+
+	state, err := sync.NewStateStore(config.StatePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open state store: %w", err)
+	}
+
+	return state, nil
+}
@@ -17,6 +17,7 @@ import (
 const DefaultWalletPath = "/etc/cornelius/arweave_wallet.json"
 
 type Synchronizer interface {
+	EnableReload(configPath string, watchFile bool)
 	Start(context.Context) error
 }
 
@@ -52,12 +53,19 @@ func main() {
 				return fmt.Errorf("unable to load config: %w", err)
 			}
 
+			if args := scaptCtx.Args[1:]; len(args) > 0 {
+				return runCommand(ctx, logger, config, args)
+			}
+
 			logger.Info("initializing synchronizer")
-			var synchronizer Synchronizer = sync.New(logger, ardrivecliPath, config)
+			var synchronizer Synchronizer
+			synchronizer, err = sync.New(logger, ardrivecliPath, config)
 			if err != nil {
 				return fmt.Errorf("unable to initialize synchronizer: %w", err)
 			}
 
+			synchronizer.EnableReload(configPath, scaptCtx.Bool("watch"))
+
 			err = synchronizer.Start(ctx)
 			if err != nil {
 				return fmt.Errorf("unable to synchronize: %w", err)
@@ -68,7 +76,7 @@ func main() {
 		Flags: flag.Flags{
 			flag.Flag{
 				Short: "c", Long: "config",
-				Description: "Filepath of YAML config file",
+				Description: "Filepath of YAML config file, a directory of YAML files or a glob",
 				Type:        argument.String,
 				Required:    true,
 			},
@@ -84,6 +92,12 @@ func main() {
 				Type:        argument.Bool,
 				Required:    false,
 			},
+			flag.Flag{
+				Short: "w", Long: "watch",
+				Description: "Reload the configuration whenever the config file changes",
+				Type:        argument.Bool,
+				Required:    false,
+			},
 			flag.Flag{
 				Short: "l", Long: "logtype",
 				Description: "Type of logger to use. Can be text or json",
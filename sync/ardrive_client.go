@@ -16,29 +16,31 @@ type ArdriveClient struct {
 	executablePath string
 	driveId        string
 	isPublic       bool
-	enableManifest bool
 	parentFolderId string
 	walletPath     string
 	walletPassword string
+	gateway        GatewayConfig
+	upload         UploadConfig
 }
 
-func NewArdriveClient(logger log.Logger, executablePath, walletPath, walletPassword, driveId, parentFolderId string, isPublic, enableManifest bool) (*ArdriveClient, error) {
+func NewArdriveClient(logger log.Logger, executablePath string, gateway GatewayConfig, upload UploadConfig, walletPath, walletPassword, driveId, parentFolderId string, isPublic bool) (*ArdriveClient, error) {
 	return &ArdriveClient{
 		logger:         logger,
 		executablePath: executablePath,
+		gateway:        gateway,
+		upload:         upload,
 		driveId:        driveId,
 		parentFolderId: parentFolderId,
 		isPublic:       isPublic,
 		walletPath:     walletPath,
 		walletPassword: walletPassword,
-		enableManifest: enableManifest,
 	}, nil
 }
 
 func (client *ArdriveClient) exec(args ...string) ([]byte, error) {
 	args = append(args, []string{"-w", client.walletPath, "--unsafe-drive-password", client.walletPassword}...)
-	client.logger.Info(client.executablePath, "args", args)
-	resp, err := ExecCmd(client.executablePath, args...)
+	client.logger.Info(client.executablePath, "args", maskPassword(args))
+	resp, err := ExecCmdTimeout(time.Duration(client.gateway.Timeout), client.executablePath, append(args, "--gateway", client.gateway.URL)...)
 	if err != nil {
 		return nil, fmt.Errorf("unable to exec private ardrive cli command: %w", err)
 	}
@@ -47,12 +49,44 @@ func (client *ArdriveClient) exec(args ...string) ([]byte, error) {
 
 }
 
+// execUpload runs an upload command against the upload endpoint of the
+// gateway, or through the bundler when the turbo strategy is selected.
+func (client *ArdriveClient) execUpload(args ...string) ([]byte, error) {
+	args = append(args, []string{"-w", client.walletPath, "--unsafe-drive-password", client.walletPassword}...)
+	if client.upload.Strategy == UploadStrategyTurbo {
+		args = append(args, "--turbo")
+		if client.upload.TurboURL != "" {
+			args = append(args, "--turbo-url", client.upload.TurboURL)
+		}
+	}
+	client.logger.Info(client.executablePath, "args", maskPassword(args))
+	resp, err := ExecCmdTimeout(time.Duration(client.gateway.UploadTimeout), client.executablePath, append(args, "--gateway", client.gateway.UploadURL)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to exec ardrive cli upload command: %w", err)
+	}
+
+	return resp, nil
+}
+
+// maskPassword returns a copy of args with the drive password replaced, for
+// logging.
+func maskPassword(args []string) []string {
+	masked := append([]string{}, args...)
+	for i := 0; i < len(masked)-1; i++ {
+		if masked[i] == "--unsafe-drive-password" {
+			masked[i+1] = "***"
+		}
+	}
+
+	return masked
+}
+
 func (client *ArdriveClient) execPrivateOrPublic(args ...string) ([]byte, error) {
 	if !client.isPublic {
 		args = append(args, []string{"-w", client.walletPath, "--unsafe-drive-password", client.walletPassword}...)
 	}
 
-	resp, err := ExecCmd(client.executablePath, args...)
+	resp, err := ExecCmdTimeout(time.Duration(client.gateway.Timeout), client.executablePath, append(args, "--gateway", client.gateway.URL)...)
 	if err != nil {
 		return nil, fmt.Errorf("unable to exec ardrive cli command: %w", err)
 	}
@@ -121,7 +155,8 @@ func (client *ArdriveClient) recursiveGenerateFolderPath(folderId string) (strin
 		return "", err
 	}
 
-	return path.Join(ardriveFolderInfo.Name, childPath), nil
+	// childPath is the path of the parent folder, the folder's name goes last
+	return path.Join(childPath, ardriveFolderInfo.Name), nil
 }
 
 func (client *ArdriveClient) GetParentPath() (string, error) {
@@ -144,6 +179,9 @@ func (client *ArdriveClient) ListFiles() (ArdriveFiles, error) {
 	for _, ardrivefileInfo := range results {
 		foundFiles = append(foundFiles, ArdriveFile{
 			Path:         ardrivefileInfo.Path,
+			EntityType:   ardrivefileInfo.EntityType,
+			EntityId:     ardrivefileInfo.EntityId,
+			DataTxId:     ardrivefileInfo.DataTxId,
 			Mimetype:     ardrivefileInfo.DataContentType,
 			LastModified: time.Unix(ardrivefileInfo.LastModifiedDate, 0),
 		})
@@ -165,7 +203,13 @@ func (client *ArdriveClient) upsertFile(localFile LocalFile) (TxData, error) {
 		args = append(args, "--content-type", localFile.Mimetype)
 	}
 
-	resp, err := client.exec(args...)
+	customMetadataArgs, err := customMetadataArgs(localFile.CustomMetadata)
+	if err != nil {
+		return TxData{}, err
+	}
+	args = append(args, customMetadataArgs...)
+
+	resp, err := client.execUpload(args...)
 	if err != nil {
 		return TxData{}, fmt.Errorf("unable to upsert ardrive file: %w", err)
 	}
@@ -176,47 +220,58 @@ func (client *ArdriveClient) upsertFile(localFile LocalFile) (TxData, error) {
 		return TxData{}, fmt.Errorf("unable to parse upload-file response: %w", err)
 	}
 
-	if client.enableManifest && localFile.Filename() == "index.html" {
-		err = client.createManifest(results.EntityId())
-		if err != nil {
-			return TxData{}, fmt.Errorf("unable to create corresponding manifest response: %w", err)
-		}
-	}
-
 	return results, nil
 }
 
-func (client *ArdriveClient) createManifest(indexEntityId string) error {
-	args := []string{
-		"file-info",
-		"--file-id",
-		indexEntityId,
-	}
-
-	resp, err := client.exec(args...)
+// upsertManifest uploads a staged manifest file into the parent folder.
+func (client *ArdriveClient) upsertManifest(manifestFile LocalFile) (TxData, error) {
+	client.logger.Info("uploading manifest", "parent_id", client.parentFolderId, "name", manifestFile.Filename())
+	resp, err := client.execUpload(
+		"upload-file",
+		"--parent-folder-id",
+		client.parentFolderId,
+		"--local-path",
+		manifestFile.Path,
+		"--content-type",
+		ManifestContentType,
+	)
 	if err != nil {
-		return fmt.Errorf("unable to upsert ardrive file: %w", err)
+		return TxData{}, fmt.Errorf("unable to upload manifest: %w", err)
 	}
 
-	indexEntity := ArdriveFileInfo{}
-	err = json.Unmarshal(resp, &indexEntity)
+	results := TxData{}
+	err = json.Unmarshal(resp, &results)
 	if err != nil {
-		return fmt.Errorf("unable to parse file-info response: %w", err)
+		return TxData{}, fmt.Errorf("unable to parse upload-file response: %w", err)
 	}
 
-	args = []string{
-		"create-manifest",
-		"--f",
-		indexEntity.ParentFolderId,
+	return results, nil
+}
+
+func customMetadataArgs(customMetadata ArfsCustomMetadata) ([]string, error) {
+	args := []string{}
+	flags := []struct {
+		flag   string
+		values map[string]string
+	}{
+		{"--metadata-json", customMetadata.MetadataJson},
+		{"--metadata-gql-tags", customMetadata.MetadataGqlTags},
+		{"--data-gql-tags", customMetadata.DataGqlTags},
 	}
 
-	client.logger.Info("creating manifest", "parent_id", indexEntity.ParentFolderId, "index_entity_id", indexEntityId)
-	_, err = client.exec(args...)
-	if err != nil {
-		return fmt.Errorf("unable to create manifest file: %w", err)
+	for _, f := range flags {
+		if len(f.values) == 0 {
+			continue
+		}
+
+		encoded, err := json.Marshal(f.values)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode %s: %w", f.flag, err)
+		}
+		args = append(args, f.flag, string(encoded))
 	}
 
-	return nil
+	return args, nil
 }
 
 func pathWithoutRootFolder(fullPath string) string {
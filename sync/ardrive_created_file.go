@@ -15,8 +15,8 @@ type File struct {
 	Type         string `json:"type"`
 	EntityName   string `json:"entityName"`
 	EntityId     string `json:"entityId"`
-	DataTxId     string `json:"data_tx_id,omitempty"`
-	MetadataTxId string `json:"metadata_tx_id,omitempty"`
+	DataTxId     string `json:"dataTxId,omitempty"`
+	MetadataTxId string `json:"metadataTxId,omitempty"`
 	BundledIn    string `json:"bundledIn,omitempty"`
 	SourceUri    string `json:"sourceUri,omitempty"`
 }
@@ -60,3 +60,13 @@ func (tx TxData) EntityId() string {
 	}
 	return ""
 }
+
+// CreatedFile returns the first file entity created by the transaction.
+func (tx TxData) CreatedFile() (File, bool) {
+	for _, f := range tx.Created {
+		if f.Type == "file" {
+			return f, true
+		}
+	}
+	return File{}, false
+}
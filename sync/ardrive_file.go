@@ -6,6 +6,9 @@ import (
 
 type ArdriveFile struct {
 	Path         string
+	EntityType   string
+	EntityId     string
+	DataTxId     string
 	Mimetype     string
 	LastModified time.Time
 }
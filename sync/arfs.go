@@ -0,0 +1,121 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/the-singularity-labs/cornelius/arweave"
+)
+
+const (
+	arfsVersion         = "0.13"
+	arfsAppName         = "cornelius"
+	arfsEntityFile      = "file"
+	arfsEntityFolder    = "folder"
+	arfsMetadataContent = "application/json"
+)
+
+// arfsFile describes a file entity of a public drive along with the data
+// items holding its metadata and data.
+type arfsFile struct {
+	EntityId     string
+	MetadataItem *arweave.DataItem
+	DataItem     *arweave.DataItem
+}
+
+// newArfsFolder builds the metadata data item of a new public folder.
+func newArfsFolder(wallet *arweave.Wallet, driveId, parentFolderId, name string) (string, *arweave.DataItem, error) {
+	folderId := uuid.NewString()
+	metadata, err := json.Marshal(map[string]string{"name": name})
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to encode folder metadata: %w", err)
+	}
+
+	item, err := arweave.NewDataItem(wallet, "", arfsTags(driveId, arfsEntityFolder,
+		arweave.Tag{Name: "Folder-Id", Value: folderId},
+		arweave.Tag{Name: "Parent-Folder-Id", Value: parentFolderId},
+	), metadata)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to build folder %q: %w", name, err)
+	}
+
+	return folderId, item, nil
+}
+
+// newArfsFile builds the data and metadata data items of a public file,
+// creating a new revision when entityId is set.
+func newArfsFile(wallet *arweave.Wallet, driveId, parentFolderId, entityId, name string, localFile LocalFile, data []byte, lastModified time.Time) (arfsFile, error) {
+	if entityId == "" {
+		entityId = uuid.NewString()
+	}
+
+	dataTags := []arweave.Tag{{Name: "Content-Type", Value: localFile.Mimetype}}
+	dataTags = append(dataTags, sortedTags(localFile.CustomMetadata.DataGqlTags)...)
+	dataItem, err := arweave.NewDataItem(wallet, "", dataTags, data)
+	if err != nil {
+		return arfsFile{}, fmt.Errorf("unable to build data of %q: %w", name, err)
+	}
+
+	metadata := map[string]any{}
+	for key, value := range localFile.CustomMetadata.MetadataJson {
+		metadata[key] = value
+	}
+	metadata["name"] = name
+	metadata["size"] = len(data)
+	metadata["lastModifiedDate"] = lastModified.UnixMilli()
+	metadata["dataTxId"] = dataItem.Id
+	metadata["dataContentType"] = localFile.Mimetype
+
+	encodedMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		return arfsFile{}, fmt.Errorf("unable to encode metadata of %q: %w", name, err)
+	}
+
+	metadataTags := arfsTags(driveId, arfsEntityFile,
+		arweave.Tag{Name: "File-Id", Value: entityId},
+		arweave.Tag{Name: "Parent-Folder-Id", Value: parentFolderId},
+	)
+	metadataTags = append(metadataTags, sortedTags(localFile.CustomMetadata.MetadataGqlTags)...)
+	metadataItem, err := arweave.NewDataItem(wallet, "", metadataTags, encodedMetadata)
+	if err != nil {
+		return arfsFile{}, fmt.Errorf("unable to build metadata of %q: %w", name, err)
+	}
+
+	return arfsFile{
+		EntityId:     entityId,
+		MetadataItem: metadataItem,
+		DataItem:     dataItem,
+	}, nil
+}
+
+func arfsTags(driveId, entityType string, entityTags ...arweave.Tag) []arweave.Tag {
+	tags := []arweave.Tag{
+		{Name: "App-Name", Value: arfsAppName},
+		{Name: "ArFS", Value: arfsVersion},
+		{Name: "Content-Type", Value: arfsMetadataContent},
+		{Name: "Drive-Id", Value: driveId},
+		{Name: "Entity-Type", Value: entityType},
+		{Name: "Unix-Time", Value: strconv.FormatInt(time.Now().Unix(), 10)},
+	}
+
+	return append(tags, entityTags...)
+}
+
+func sortedTags(values map[string]string) []arweave.Tag {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tags := make([]arweave.Tag, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, arweave.Tag{Name: name, Value: values[name]})
+	}
+
+	return tags
+}
@@ -0,0 +1,125 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/the-singularity-labs/cornelius/arweave"
+)
+
+const (
+	DefaultArnsEndpoint  = "https://mu.ao-testnet.xyz"
+	DefaultArnsUndername = "@"
+	DefaultArnsTTL       = Duration(time.Hour)
+
+	// ANT handlers only read tags, the message data is a placeholder.
+	arnsMessageData = "1234"
+
+	minArnsTTL = Duration(time.Minute)
+	maxArnsTTL = Duration(24 * time.Hour)
+)
+
+// ArnsConfig points an ArNS name at the newest manifest of a pipeline by
+// sending a Set-Record message to the ANT process owning the name.
+type ArnsConfig struct {
+	ProcessId string   `yaml:"process_id"`
+	Undername string   `yaml:"undername"`
+	TTL       Duration `yaml:"ttl"`
+	Endpoint  string   `yaml:"endpoint"`
+	Timeout   Duration `yaml:"timeout"`
+}
+
+// ArnsClient signs ANT messages with the drive wallet and posts them to an
+// AO messenger unit.
+type ArnsClient struct {
+	httpClient *http.Client
+	wallet     *arweave.Wallet
+	endpoint   string
+	processId  string
+	undername  string
+	ttl        Duration
+}
+
+func NewArnsClient(config ArnsConfig, walletPath string) (*ArnsClient, error) {
+	if config.ProcessId == "" {
+		return nil, fmt.Errorf("arns process_id is not set")
+	}
+
+	undername := config.Undername
+	if undername == "" {
+		undername = DefaultArnsUndername
+	}
+
+	ttl := config.TTL
+	if ttl == 0 {
+		ttl = DefaultArnsTTL
+	} else if ttl < minArnsTTL || ttl > maxArnsTTL {
+		return nil, fmt.Errorf("arns ttl must be between %s and %s", time.Duration(minArnsTTL), time.Duration(maxArnsTTL))
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultArnsEndpoint
+	}
+
+	timeout := time.Duration(config.Timeout)
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	wallet, err := arweave.LoadWallet(walletPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ArnsClient{
+		httpClient: &http.Client{Timeout: timeout},
+		wallet:     wallet,
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		processId:  config.ProcessId,
+		undername:  undername,
+		ttl:        ttl,
+	}, nil
+}
+
+// SetRecord points the undername at txId and returns the id of the message sent.
+func (client *ArnsClient) SetRecord(ctx context.Context, txId string) (string, error) {
+	dataItem, err := arweave.NewDataItem(client.wallet, client.processId, []arweave.Tag{
+		{Name: "Data-Protocol", Value: "ao"},
+		{Name: "Variant", Value: "ao.TN.1"},
+		{Name: "Type", Value: "Message"},
+		{Name: "SDK", Value: "cornelius"},
+		{Name: "Action", Value: "Set-Record"},
+		{Name: "Sub-Domain", Value: client.undername},
+		{Name: "Transaction-Id", Value: txId},
+		{Name: "TTL-Seconds", Value: strconv.Itoa(int(time.Duration(client.ttl).Seconds()))},
+	}, []byte(arnsMessageData))
+	if err != nil {
+		return "", fmt.Errorf("unable to build arns message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.endpoint, bytes.NewReader(dataItem.Raw))
+	if err != nil {
+		return "", fmt.Errorf("unable to build arns request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to reach arns endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("arns endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return dataItem.Id, nil
+}
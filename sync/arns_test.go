@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/the-singularity-labs/cornelius/arweave/arweavetest"
+)
+
+func TestArnsClientSetRecord(t *testing.T) {
+	processId := base64.RawURLEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	manifestId := base64.RawURLEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+
+	messages := [][]byte{}
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/octet-stream" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		messages = append(messages, body)
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	client, err := NewArnsClient(ArnsConfig{
+		ProcessId: processId,
+		Undername: "docs",
+		TTL:       Duration(15 * time.Minute),
+		Endpoint:  server.URL + "/",
+	}, arweavetest.WriteWallet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messageId, err := client.SetRecord(context.Background(), manifestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected one message, got %d", len(messages))
+	}
+	if len(messageId) != 43 {
+		t.Errorf("expected a message id, got %q", messageId)
+	}
+
+	message, err := arweavetest.ParseDataItem(messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target := base64.RawURLEncoding.EncodeToString(message.Target); target != processId {
+		t.Errorf("expected the message to target %s, got %s", processId, target)
+	}
+	tags := message.TagMap()
+	for name, value := range map[string]string{
+		"Data-Protocol":  "ao",
+		"Type":           "Message",
+		"Action":         "Set-Record",
+		"Sub-Domain":     "docs",
+		"Transaction-Id": manifestId,
+		"TTL-Seconds":    "900",
+	} {
+		if tags[name] != value {
+			t.Errorf("expected tag %s to be %q, got %q", name, value, tags[name])
+		}
+	}
+}
+
+func TestArnsClientSetRecordRejected(t *testing.T) {
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "process not found", http.StatusNotFound)
+	})
+
+	processId := base64.RawURLEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	client, err := NewArnsClient(ArnsConfig{ProcessId: processId, Endpoint: server.URL}, arweavetest.WriteWallet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.SetRecord(context.Background(), processId)
+	if err == nil || !strings.Contains(err.Error(), "process not found") {
+		t.Errorf("expected the endpoint's error, got %v", err)
+	}
+}
+
+func TestNewArnsClientValidation(t *testing.T) {
+	walletPath := arweavetest.WriteWallet(t)
+
+	for name, config := range map[string]ArnsConfig{
+		"missing process": {},
+		"ttl too short":   {ProcessId: "process", TTL: Duration(time.Second)},
+		"ttl too long":    {ProcessId: "process", TTL: Duration(48 * time.Hour)},
+	} {
+		_, err := NewArnsClient(config, walletPath)
+		if err == nil {
+			t.Errorf("expected %s to be rejected", name)
+		}
+	}
+
+	client, err := NewArnsClient(ArnsConfig{ProcessId: "process"}, walletPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.undername != DefaultArnsUndername || client.ttl != DefaultArnsTTL || client.endpoint != DefaultArnsEndpoint {
+		t.Errorf("expected the defaults, got %q, %s and %q", client.undername, time.Duration(client.ttl), client.endpoint)
+	}
+}
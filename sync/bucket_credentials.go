@@ -0,0 +1,181 @@
+package sync
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	CredentialSourceStatic      = "static"
+	CredentialSourceEnv         = "env"
+	CredentialSourceFile        = "file"
+	CredentialSourceWebIdentity = "web_identity"
+	CredentialSourceIAM         = "iam"
+	CredentialSourceAssumeRole  = "assume_role"
+	CredentialSourceMinioSTS    = "minio_sts"
+)
+
+// BucketCredentials configures how credentials for a bucket are obtained.
+// Sources are tried in order and the first one yielding credentials is used.
+// Without any sources the static access_id/secret_key pair is used.
+type BucketCredentials struct {
+	Sources               []string `yaml:"sources"`
+	Profile               string   `yaml:"profile"`
+	SharedCredentialsFile string   `yaml:"shared_credentials_file"`
+	WebIdentityTokenFile  string   `yaml:"web_identity_token_file"`
+	RoleArn               string   `yaml:"role_arn"`
+	RoleSessionName       string   `yaml:"role_session_name"`
+	ExternalId            string   `yaml:"external_id"`
+	StsEndpoint           string   `yaml:"sts_endpoint"`
+	Region                string   `yaml:"region"`
+	Duration              Duration `yaml:"duration"`
+}
+
+func newBucketCredentials(bucket Bucket, accessId, secretKey string, transport http.RoundTripper) (*credentials.Credentials, error) {
+	sources := bucket.Credentials.Sources
+	if len(sources) == 0 {
+		sources = []string{CredentialSourceStatic}
+	}
+
+	providers := []credentials.Provider{}
+	for _, source := range sources {
+		provider, err := newCredentialProvider(source, bucket, accessId, secretKey, transport)
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize %q credentials: %w", source, err)
+		}
+		providers = append(providers, provider)
+	}
+
+	if len(providers) == 1 {
+		return credentials.New(providers[0]), nil
+	}
+
+	return credentials.NewChainCredentials(providers), nil
+}
+
+func newCredentialProvider(source string, bucket Bucket, accessId, secretKey string, transport http.RoundTripper) (credentials.Provider, error) {
+	config := bucket.Credentials
+	// only MinIO STS is served by the bucket host, AWS STS and metadata
+	// endpoints must not see its TLS settings or client certificate
+	httpClient := &http.Client{Transport: http.DefaultTransport}
+	if source == CredentialSourceMinioSTS {
+		httpClient = &http.Client{Transport: transport}
+	}
+
+	switch source {
+	case CredentialSourceStatic:
+		return &credentials.Static{
+			Value: credentials.Value{
+				AccessKeyID:     accessId,
+				SecretAccessKey: secretKey,
+				SignerType:      credentials.SignatureV4,
+			},
+		}, nil
+	case CredentialSourceEnv:
+		return &credentials.Chain{
+			Providers: []credentials.Provider{&credentials.EnvAWS{}, &credentials.EnvMinio{}},
+		}, nil
+	case CredentialSourceFile:
+		return &credentials.FileAWSCredentials{
+			Filename: config.SharedCredentialsFile,
+			Profile:  config.Profile,
+		}, nil
+	case CredentialSourceWebIdentity:
+		tokenFile := config.WebIdentityTokenFile
+		if tokenFile == "" {
+			tokenFile = os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+		}
+		if tokenFile == "" {
+			return nil, fmt.Errorf("web_identity_token_file is not set")
+		}
+
+		roleArn := config.RoleArn
+		if roleArn == "" {
+			roleArn = os.Getenv("AWS_ROLE_ARN")
+		}
+
+		return &credentials.STSWebIdentity{
+			Client:      httpClient,
+			STSEndpoint: stsEndpoint(config, "https://sts.amazonaws.com"),
+			RoleARN:     roleArn,
+			GetWebIDTokenExpiry: func() (*credentials.WebIdentityToken, error) {
+				token, err := os.ReadFile(tokenFile)
+				if err != nil {
+					return nil, fmt.Errorf("unable to read web identity token %q: %w", tokenFile, err)
+				}
+
+				return &credentials.WebIdentityToken{
+					Token:  strings.TrimSpace(string(token)),
+					Expiry: durationSeconds(config.Duration),
+				}, nil
+			},
+		}, nil
+	case CredentialSourceIAM:
+		// covers EC2 instance metadata, ECS task roles and EKS service
+		// accounts via the standard AWS_* environment variables
+		return &credentials.IAM{
+			Client: httpClient,
+			Region: region(config.Region, bucket.Region),
+		}, nil
+	case CredentialSourceAssumeRole, CredentialSourceMinioSTS:
+		if accessId == "" || secretKey == "" {
+			return nil, fmt.Errorf("access_id and secret_key are required to assume a role")
+		}
+
+		defaultEndpoint := "https://sts.amazonaws.com"
+		if source == CredentialSourceMinioSTS {
+			defaultEndpoint = bucketEndpointUrl(bucket)
+		}
+
+		return &credentials.STSAssumeRole{
+			Client:      httpClient,
+			STSEndpoint: stsEndpoint(config, defaultEndpoint),
+			Options: credentials.STSAssumeRoleOptions{
+				AccessKey:       accessId,
+				SecretKey:       secretKey,
+				Location:        region(config.Region, bucket.Region),
+				DurationSeconds: durationSeconds(config.Duration),
+				RoleARN:         config.RoleArn,
+				RoleSessionName: config.RoleSessionName,
+				ExternalID:      config.ExternalId,
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("%q is not a valid credential source", source)
+	}
+}
+
+func stsEndpoint(config BucketCredentials, defaultEndpoint string) string {
+	if config.StsEndpoint != "" {
+		return config.StsEndpoint
+	}
+
+	return defaultEndpoint
+}
+
+func bucketEndpointUrl(bucket Bucket) string {
+	if bucket.IsSecure {
+		return "https://" + bucket.Host
+	}
+
+	return "http://" + bucket.Host
+}
+
+func durationSeconds(d Duration) int {
+	return int(time.Duration(d) / time.Second)
+}
+
+func region(regions ...string) string {
+	for _, r := range regions {
+		if r != "" {
+			return r
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,177 @@
+package sync
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+func TestNewCredentialProvider(t *testing.T) {
+	bucketTransport := http.DefaultTransport.(*http.Transport).Clone()
+	bucket := Bucket{Host: "minio.local:9000", IsSecure: true, Region: "eu-west-1"}
+
+	for _, test := range []struct {
+		source      string
+		credentials BucketCredentials
+		accessId    string
+		check       func(provider credentials.Provider) string
+		err         string
+	}{
+		{
+			source:   CredentialSourceStatic,
+			accessId: "access",
+			check: func(provider credentials.Provider) string {
+				if static := provider.(*credentials.Static); static.AccessKeyID != "access" || static.SecretAccessKey != "secret" {
+					return "expected the access_id/secret_key pair"
+				}
+				return ""
+			},
+		},
+		{
+			source: CredentialSourceEnv,
+			check: func(provider credentials.Provider) string {
+				if chain := provider.(*credentials.Chain); len(chain.Providers) != 2 {
+					return "expected the AWS and MinIO environment variables"
+				}
+				return ""
+			},
+		},
+		{
+			source:      CredentialSourceFile,
+			credentials: BucketCredentials{SharedCredentialsFile: "/etc/aws/credentials", Profile: "backup"},
+			check: func(provider credentials.Provider) string {
+				if file := provider.(*credentials.FileAWSCredentials); file.Filename != "/etc/aws/credentials" || file.Profile != "backup" {
+					return "expected the shared credentials file and profile"
+				}
+				return ""
+			},
+		},
+		{
+			source:      CredentialSourceWebIdentity,
+			credentials: BucketCredentials{WebIdentityTokenFile: "/var/run/token", RoleArn: "arn:role"},
+			check: func(provider credentials.Provider) string {
+				webIdentity := provider.(*credentials.STSWebIdentity)
+				if webIdentity.STSEndpoint != "https://sts.amazonaws.com" || webIdentity.RoleARN != "arn:role" {
+					return "expected AWS STS and the role"
+				} else if webIdentity.Client.Transport == bucketTransport {
+					return "expected AWS STS not to use the bucket TLS settings"
+				}
+				return ""
+			},
+		},
+		{
+			source: CredentialSourceWebIdentity,
+			err:    "web_identity_token_file is not set",
+		},
+		{
+			source: CredentialSourceIAM,
+			check: func(provider credentials.Provider) string {
+				if iam := provider.(*credentials.IAM); iam.Region != "eu-west-1" || iam.Client.Transport == bucketTransport {
+					return "expected the bucket region without the bucket TLS settings"
+				}
+				return ""
+			},
+		},
+		{
+			source:      CredentialSourceAssumeRole,
+			credentials: BucketCredentials{RoleArn: "arn:role", Region: "us-east-2", Duration: Duration(time.Hour)},
+			accessId:    "access",
+			check: func(provider credentials.Provider) string {
+				assumeRole := provider.(*credentials.STSAssumeRole)
+				if assumeRole.STSEndpoint != "https://sts.amazonaws.com" || assumeRole.Options.Location != "us-east-2" || assumeRole.Options.DurationSeconds != 3600 {
+					return "expected AWS STS in the configured region"
+				} else if assumeRole.Client.Transport == bucketTransport {
+					return "expected AWS STS not to use the bucket TLS settings"
+				}
+				return ""
+			},
+		},
+		{
+			source: CredentialSourceAssumeRole,
+			err:    "access_id and secret_key are required",
+		},
+		{
+			source:   CredentialSourceMinioSTS,
+			accessId: "access",
+			check: func(provider credentials.Provider) string {
+				assumeRole := provider.(*credentials.STSAssumeRole)
+				if assumeRole.STSEndpoint != "https://minio.local:9000" || assumeRole.Options.Location != "eu-west-1" {
+					return "expected the STS endpoint of the bucket host"
+				} else if assumeRole.Client.Transport != bucketTransport {
+					return "expected MinIO STS to use the bucket TLS settings"
+				}
+				return ""
+			},
+		},
+		{
+			source:      CredentialSourceMinioSTS,
+			credentials: BucketCredentials{StsEndpoint: "https://sts.local"},
+			accessId:    "access",
+			check: func(provider credentials.Provider) string {
+				if provider.(*credentials.STSAssumeRole).STSEndpoint != "https://sts.local" {
+					return "expected the configured sts_endpoint"
+				}
+				return ""
+			},
+		},
+		{
+			source: "vault",
+			err:    "is not a valid credential source",
+		},
+	} {
+		t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+		secretKey := ""
+		if test.accessId != "" {
+			secretKey = "secret"
+		}
+
+		bucket.Credentials = test.credentials
+		provider, err := newCredentialProvider(test.source, bucket, test.accessId, secretKey, bucketTransport)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("expected %s to fail with %q, got %v", test.source, test.err, err)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("expected %s to succeed, got %v", test.source, err)
+			continue
+		}
+
+		if message := test.check(provider); message != "" {
+			t.Errorf("%s: %s, got %+v", test.source, message, provider)
+		}
+	}
+}
+
+func TestNewBucketCredentials(t *testing.T) {
+	creds, err := newBucketCredentials(Bucket{}, "access", "secret", http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := creds.Get()
+	if err != nil || value.AccessKeyID != "access" {
+		t.Errorf("expected the static credentials without sources, got %+v, %v", value, err)
+	}
+
+	// the first source yielding credentials is used
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("MINIO_ACCESS_KEY", "")
+	t.Setenv("MINIO_ROOT_USER", "")
+	creds, err = newBucketCredentials(Bucket{Credentials: BucketCredentials{Sources: []string{CredentialSourceEnv, CredentialSourceStatic}}}, "access", "secret", http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err = creds.Get()
+	if err != nil || value.AccessKeyID != "access" {
+		t.Errorf("expected the chain to fall back to the static credentials, got %+v, %v", value, err)
+	}
+
+	_, err = newBucketCredentials(Bucket{Credentials: BucketCredentials{Sources: []string{CredentialSourceStatic, "vault"}}}, "", "", http.DefaultTransport)
+	if err == nil || !strings.Contains(err.Error(), `unable to initialize "vault" credentials`) {
+		t.Errorf("expected an invalid source to be rejected, got %v", err)
+	}
+}
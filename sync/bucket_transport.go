@@ -0,0 +1,85 @@
+package sync
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	BucketAddressingAuto    = "auto"
+	BucketAddressingPath    = "path"
+	BucketAddressingVirtual = "virtual"
+)
+
+type BucketTLS struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+func bucketLookup(addressing string) (minio.BucketLookupType, error) {
+	switch addressing {
+	case "", BucketAddressingAuto:
+		return minio.BucketLookupAuto, nil
+	case BucketAddressingPath:
+		return minio.BucketLookupPath, nil
+	case BucketAddressingVirtual:
+		return minio.BucketLookupDNS, nil
+	default:
+		return minio.BucketLookupAuto, fmt.Errorf("%q is not a valid bucket addressing style", addressing)
+	}
+}
+
+func newBucketTransport(bucket Bucket) (*http.Transport, error) {
+	transport, err := minio.DefaultTransport(bucket.IsSecure)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize transport: %w", err)
+	}
+
+	config := bucket.TLS
+	if !bucket.IsSecure || config == (BucketTLS{}) {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read ca bundle %q: %w", config.CAFile, err)
+		}
+
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in ca bundle %q", config.CAFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
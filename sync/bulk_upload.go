@@ -0,0 +1,466 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path"
+	"time"
+
+	"github.com/the-singularity-labs/cornelius/arweave"
+)
+
+const (
+	DefaultBulkMaxItems = 500
+	DefaultBulkMaxBytes = ByteSize(100 * 1024 * 1024)
+)
+
+// BulkConfig groups the files of an iteration into ANS-104 bundles, so many
+// files are paid for with a single L1 transaction instead of one upload each.
+// A bundle is closed once it holds MaxItems files or MaxBytes of file data,
+// files larger than MaxBytes are uploaded on their own through ardrive-cli.
+type BulkConfig struct {
+	Enabled  bool     `yaml:"enabled"`
+	MaxItems int      `yaml:"max_items"`
+	MaxBytes ByteSize `yaml:"max_bytes"`
+}
+
+// bulkUploader builds bundles of public ArFS entities and posts them
+// straight to the gateway.
+type bulkUploader struct {
+	wallet         *arweave.Wallet
+	gateway        *arweave.Gateway
+	driveId        string
+	parentFolderId string
+	maxItems       int
+	maxBytes       int64
+}
+
+// bundleEntry is a file of a bundle along with the entity created for it.
+type bundleEntry struct {
+	object    ObjectStorageFile
+	localFile LocalFile
+	ipfsCid   string
+	path      string
+	file      arfsFile
+}
+
+func newBulkUploader(config BulkConfig, wallet *arweave.Wallet, gateway *arweave.Gateway, driveId, parentFolderId string) (*bulkUploader, error) {
+	maxItems := config.MaxItems
+	if maxItems == 0 {
+		maxItems = DefaultBulkMaxItems
+	} else if maxItems < 0 {
+		return nil, fmt.Errorf("max_items must be positive")
+	}
+
+	maxBytes := config.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = DefaultBulkMaxBytes
+	} else if maxBytes < 0 {
+		return nil, fmt.Errorf("max_bytes must be positive")
+	}
+
+	return &bulkUploader{
+		wallet:         wallet,
+		gateway:        gateway,
+		driveId:        driveId,
+		parentFolderId: parentFolderId,
+		maxItems:       maxItems,
+		maxBytes:       int64(maxBytes),
+	}, nil
+}
+
+// partition separates the files to upload in bundles from the files uploaded
+// through ardrive-cli: split objects and objects exceeding the bundle size,
+// since bundles are built in memory.
+func (uploader *bulkUploader) partition(files ObjectStorageFiles, maxFileSize int64) (ObjectStorageFiles, ObjectStorageFiles) {
+	bundled := ObjectStorageFiles{}
+	singles := ObjectStorageFiles{}
+	for _, file := range files {
+		if file.Size > maxFileSize || file.Size > uploader.maxBytes {
+			singles = append(singles, file)
+		} else {
+			bundled = append(bundled, file)
+		}
+	}
+
+	return bundled, singles
+}
+
+// batches groups files by the item and byte thresholds. Files exceeding
+// maxBytes on their own are expected to be uploaded outside of bundles.
+func (uploader *bulkUploader) batches(files ObjectStorageFiles) []ObjectStorageFiles {
+	batches := []ObjectStorageFiles{}
+	batch := ObjectStorageFiles{}
+	batchBytes := int64(0)
+
+	for _, file := range files {
+		if len(batch) > 0 && (len(batch) >= uploader.maxItems || batchBytes+file.Size > uploader.maxBytes) {
+			batches = append(batches, batch)
+			batch = ObjectStorageFiles{}
+			batchBytes = 0
+		}
+		batch = append(batch, file)
+		batchBytes += file.Size
+	}
+
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+
+	return batches
+}
+
+// syncBulk uploads the files in bundles and returns how many were uploaded.
+func (run *pipelineRun) syncBulk(ctx context.Context, files ObjectStorageFiles, ardriveFiles ArdriveFiles) (int, error) {
+	folders := map[string]string{run.parentPath: run.bulk.parentFolderId}
+	entities := map[string]string{}
+	for _, ardriveFile := range ardriveFiles {
+		switch ardriveFile.EntityType {
+		case arfsEntityFolder:
+			folders[ardriveFile.Path] = ardriveFile.EntityId
+		case arfsEntityFile:
+			entities[ardriveFile.Path] = ardriveFile.EntityId
+		}
+	}
+
+	uploaded := 0
+	for _, batch := range run.bulk.batches(files) {
+		if ctx.Err() != nil {
+			run.logger.Info("pipeline stopped, skipping remaining bundles")
+			break
+		}
+
+		bundled, stop, err := run.uploadBundle(ctx, batch, folders, entities)
+		uploaded += bundled
+		if err != nil {
+			return uploaded, err
+		} else if stop {
+			break
+		}
+	}
+
+	return uploaded, nil
+}
+
+// builtBundle holds the entities of a bundle and, once signed, its transaction.
+type builtBundle struct {
+	tx         *arweave.Transaction
+	items      []*arweave.DataItem
+	entries    []bundleEntry
+	newFolders map[string]string
+}
+
+// fees splits the reward of the bundle among its entries by the size of
+// their data items. The last entry takes what division leaves over, so the
+// fees of the entries add up to the reward, folders included.
+func (built *builtBundle) fees() []string {
+	reward, ok := new(big.Int).SetString(built.tx.Reward, 10)
+	if !ok {
+		reward = new(big.Int)
+	}
+
+	sizes := []int64{}
+	total := int64(0)
+	for _, entry := range built.entries {
+		size := int64(len(entry.file.DataItem.Raw) + len(entry.file.MetadataItem.Raw))
+		sizes = append(sizes, size)
+		total += size
+	}
+
+	fees := []string{}
+	remaining := new(big.Int).Set(reward)
+	for i, size := range sizes {
+		fee := new(big.Int).Set(remaining)
+		if i < len(sizes)-1 && total > 0 {
+			fee.Mul(reward, big.NewInt(size))
+			fee.Quo(fee, big.NewInt(total))
+		}
+		remaining.Sub(remaining, fee)
+		fees = append(fees, fee.String())
+	}
+
+	return fees
+}
+
+func (built *builtBundle) objects() ObjectStorageFiles {
+	objects := ObjectStorageFiles{}
+	for _, entry := range built.entries {
+		objects = append(objects, entry.object)
+	}
+
+	return objects
+}
+
+// folderId returns the id of the folder at folderPath, adding data items for
+// any folder that does not exist yet. Paths that are not below a known
+// folder, the parent folder at least, are refused.
+func (built *builtBundle) folderId(uploader *bulkUploader, folders map[string]string, folderPath string) (string, error) {
+	if id, exists := folders[folderPath]; exists {
+		return id, nil
+	} else if id, exists := built.newFolders[folderPath]; exists {
+		return id, nil
+	} else if folderPath == "/" || folderPath == "." {
+		return "", fmt.Errorf("reached %q without finding a known folder", folderPath)
+	}
+
+	parentId, err := built.folderId(uploader, folders, path.Dir(folderPath))
+	if err != nil {
+		return "", err
+	}
+
+	id, item, err := newArfsFolder(uploader.wallet, uploader.driveId, parentId, path.Base(folderPath))
+	if err != nil {
+		return "", err
+	}
+	built.newFolders[folderPath] = id
+	built.items = append(built.items, item)
+
+	return id, nil
+}
+
+// uploadBundle builds, posts and records a bundle of the batch. It returns
+// how many files were uploaded and whether the remaining uploads should be
+// skipped. Objects that cannot be staged are left out of the bundle and
+// handled as failures of their own. Posting is retried separately from
+// building, a rebuilt bundle would be a new transaction paid for again.
+func (run *pipelineRun) uploadBundle(ctx context.Context, batch ObjectStorageFiles, folders, entities map[string]string) (int, bool, error) {
+	built := &builtBundle{newFolders: map[string]string{}}
+
+	folderId := func(folderPath string) (string, error) {
+		return built.folderId(run.bulk, folders, folderPath)
+	}
+
+	for _, object := range batch {
+		var entry bundleEntry
+		err := run.retry.retry(ctx, run.logger.With("object", object.Key), func() error {
+			var err error
+			entry, err = run.prepareBundleEntry(object, folderId, entities)
+			return err
+		})
+		if err != nil {
+			stop, err := run.uploadFailed(ObjectStorageFiles{object}, err)
+			if err != nil || stop {
+				return 0, stop, err
+			}
+			continue
+		}
+		built.items = append(built.items, entry.file.DataItem, entry.file.MetadataItem)
+		built.entries = append(built.entries, entry)
+	}
+
+	if len(built.entries) == 0 {
+		return 0, false, nil
+	}
+
+	err := run.retry.retry(ctx, run.logger, func() error {
+		return run.signBundle(ctx, built)
+	})
+	if err == nil {
+		err = run.retry.retry(ctx, run.logger.With("bundle_tx_id", built.tx.Id), func() error {
+			return run.bulk.gateway.PostTransaction(ctx, built.tx)
+		})
+		if err != nil {
+			err = fmt.Errorf("unable to upload bundle %q: %w", built.tx.Id, err)
+		}
+	}
+	if err == nil {
+		err = run.recordBundle(built, folders, entities)
+	}
+	if err != nil {
+		stop, err := run.uploadFailed(built.objects(), err)
+		return 0, stop, err
+	}
+
+	run.failures = 0
+	return len(built.entries), false, nil
+}
+
+// signBundle signs a bundle transaction holding the data items of the bundle.
+func (run *pipelineRun) signBundle(ctx context.Context, built *builtBundle) error {
+	bundle, err := arweave.NewBundle(built.items)
+	if err != nil {
+		return fmt.Errorf("unable to build bundle: %w", err)
+	}
+
+	anchor, err := run.bulk.gateway.TxAnchor(ctx)
+	if err != nil {
+		return err
+	}
+
+	reward, err := run.bulk.gateway.Price(ctx, len(bundle))
+	if err != nil {
+		return err
+	}
+
+	tags := append([]arweave.Tag{{Name: "App-Name", Value: arfsAppName}}, arweave.BundleTags...)
+	built.tx, err = arweave.NewTransaction(run.bulk.wallet, tags, bundle, anchor, reward)
+	if err != nil {
+		return fmt.Errorf("unable to build bundle transaction: %w", err)
+	}
+
+	return nil
+}
+
+// recordBundle records the entities of a posted bundle as pending, in the
+// state and in receipts.
+func (run *pipelineRun) recordBundle(built *builtBundle, folders, entities map[string]string) error {
+	tx, entries, newFolders := built.tx, built.entries, built.newFolders
+	run.logger.Info("bundle uploaded to arweave", "bundle_tx_id", tx.Id, "files", len(entries), "folders", len(newFolders), "bytes", tx.DataSize, "fees_paid", tx.Reward)
+
+	now := time.Now()
+	for folderPath, id := range newFolders {
+		folders[folderPath] = id
+		run.pending = append(run.pending, ArdriveFile{Path: folderPath, EntityType: arfsEntityFolder, EntityId: id, LastModified: now})
+	}
+
+	err := run.state.UpdatePipeline(run.pipeline.Name, func(pipelineState *PipelineState) {
+		for folderPath, id := range newFolders {
+			pipelineState.Folders[folderPath] = &FolderState{EntityId: id, BundledIn: tx.Id}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record folders of bundle %q: %w", tx.Id, err)
+	}
+
+	fees := built.fees()
+	for i, entry := range entries {
+		entities[entry.path] = entry.file.EntityId
+		run.pending = append(run.pending, ArdriveFile{
+			Path:         entry.path,
+			EntityType:   arfsEntityFile,
+			EntityId:     entry.file.EntityId,
+			DataTxId:     entry.file.DataItem.Id,
+			Mimetype:     entry.localFile.Mimetype,
+			LastModified: now,
+		})
+
+		run.logger.Info("file uploaded to arweave", "object", entry.object.Key, "entity_id", entry.file.EntityId, "data_tx_id", entry.file.DataItem.Id, "metadata_tx_id", entry.file.MetadataItem.Id, "bundled_in", tx.Id)
+
+		receipt := Receipt{
+			Key:          entry.object.Key,
+			Size:         entry.object.Size,
+			Sha256:       entry.localFile.Sha256,
+			EntityId:     entry.file.EntityId,
+			DataTxId:     entry.file.DataItem.Id,
+			MetadataTxId: entry.file.MetadataItem.Id,
+			BundledIn:    tx.Id,
+			Fees:         fees[i],
+			UploadedAt:   now,
+		}
+		run.addReceipt(receipt)
+		run.writeBack(receipt)
+
+		err = run.state.UpdateFile(run.pipeline.Name, entry.object.Key, func(fileState *FileState) {
+			fileState.Size = entry.object.Size
+			fileState.Sha256 = entry.localFile.Sha256
+			fileState.IpfsCid = entry.ipfsCid
+			fileState.EntityId = entry.file.EntityId
+			fileState.DataTxId = entry.file.DataItem.Id
+			fileState.MetadataTxId = entry.file.MetadataItem.Id
+			fileState.BundledIn = tx.Id
+			fileState.markUploaded(now)
+			fileState.DrivePath = entry.path
+		})
+		if err != nil {
+			return fmt.Errorf("unable to record state of %q: %w", entry.object.Key, err)
+		}
+	}
+
+	return nil
+}
+
+func (run *pipelineRun) prepareBundleEntry(object ObjectStorageFile, folderId func(string) (string, error), entities map[string]string) (bundleEntry, error) {
+	localFile, ipfsCid, err := run.prepareObject(object)
+	defer removeLocalFile(localFile)
+	if err != nil {
+		return bundleEntry{}, err
+	}
+
+	data, err := os.ReadFile(localFile.Path)
+	if err != nil {
+		return bundleEntry{}, fmt.Errorf("unable to read staged object %q: %w", object.Key, err)
+	}
+
+	if !keyWithinParent(run.parentPath, object.Key) {
+		return bundleEntry{}, fmt.Errorf("object key %q leaves the parent folder %q", object.Key, run.parentPath)
+	}
+
+	filePath := path.Join(run.parentPath, object.Key)
+	parentId, err := folderId(path.Dir(filePath))
+	if err != nil {
+		return bundleEntry{}, err
+	}
+
+	file, err := newArfsFile(run.bulk.wallet, run.bulk.driveId, parentId, entities[filePath], path.Base(filePath), localFile, data, object.LastModified)
+	if err != nil {
+		return bundleEntry{}, err
+	}
+
+	return bundleEntry{
+		object:    object,
+		localFile: localFile,
+		ipfsCid:   ipfsCid,
+		path:      filePath,
+		file:      file,
+	}, nil
+}
+
+// restorePending rebuilds the files and folders uploaded in bundles from the
+// state, so uploads the gateway had not indexed before a restart are neither
+// uploaded again as new entities nor missing from the manifest. Entries the
+// drive listing already holds are dropped on the next iteration.
+func (run *pipelineRun) restorePending() {
+	for folderPath, folderState := range run.state.Pipeline(run.pipeline.Name).Folders {
+		run.pending = append(run.pending, ArdriveFile{Path: folderPath, EntityType: arfsEntityFolder, EntityId: folderState.EntityId})
+	}
+
+	for _, fileState := range run.state.Files(run.pipeline.Name) {
+		if fileState.DrivePath == "" || fileState.Dropped {
+			continue
+		}
+
+		run.pending = append(run.pending, ArdriveFile{
+			Path:         fileState.DrivePath,
+			EntityType:   arfsEntityFile,
+			EntityId:     fileState.EntityId,
+			DataTxId:     fileState.DataTxId,
+			LastModified: fileState.UploadedAt,
+		})
+	}
+}
+
+// withPending adds files uploaded in bundles or through ardrive-cli that the
+// gateway has not indexed yet to a drive listing, so they are neither
+// uploaded again nor missing from the manifest.
+func (run *pipelineRun) withPending(ardriveFiles ArdriveFiles) ArdriveFiles {
+	if len(run.pending) == 0 {
+		return ardriveFiles
+	}
+
+	listed := map[string]ArdriveFile{}
+	for _, ardriveFile := range ardriveFiles {
+		listed[ardriveFile.Path] = ardriveFile
+	}
+
+	pending := ArdriveFiles{}
+	pendingPaths := map[string]bool{}
+	for _, pendingFile := range run.pending {
+		if listedFile, exists := listed[pendingFile.Path]; exists && listedFile.EntityId == pendingFile.EntityId && listedFile.DataTxId == pendingFile.DataTxId {
+			continue
+		}
+		pending = append(pending, pendingFile)
+		pendingPaths[pendingFile.Path] = true
+	}
+	run.pending = pending
+
+	merged := ArdriveFiles{}
+	for _, ardriveFile := range ardriveFiles {
+		if !pendingPaths[ardriveFile.Path] {
+			merged = append(merged, ardriveFile)
+		}
+	}
+
+	return append(merged, pending...)
+}
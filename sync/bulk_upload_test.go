@@ -0,0 +1,109 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/the-singularity-labs/cornelius/arweave"
+	"github.com/the-singularity-labs/cornelius/arweave/arweavetest"
+)
+
+func TestKeyWithinParent(t *testing.T) {
+	for key, within := range map[string]bool{
+		"index.html":           true,
+		"assets/app.js":        true,
+		"assets/../index.html": true,
+		"./index.html":         true,
+		"../index.html":        false,
+		"a/../../index.html":   false,
+		"assets/..":            false,
+		"..":                   false,
+		"/etc/passwd":          true,
+	} {
+		if keyWithinParent("/Drive/site", key) != within {
+			t.Errorf("expected key %q within the parent folder to be %t", key, within)
+		}
+	}
+}
+
+func TestBuiltBundleFolderId(t *testing.T) {
+	wallet, err := arweave.ParseWallet(arweavetest.WalletJwk(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploader := &bulkUploader{wallet: wallet, driveId: "drive"}
+	folders := map[string]string{"/Drive/site": "parent", "/Drive/site/assets": "assets"}
+
+	built := &builtBundle{newFolders: map[string]string{}}
+	id, err := built.folderId(uploader, folders, "/Drive/site/assets")
+	if err != nil || id != "assets" {
+		t.Errorf("expected the known folder, got %q, %v", id, err)
+	}
+
+	id, err = built.folderId(uploader, folders, "/Drive/site/docs/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(built.newFolders) != 2 || len(built.items) != 2 || built.newFolders["/Drive/site/docs/v1"] != id || built.newFolders["/Drive/site/docs"] == "" {
+		t.Errorf("expected data items for docs and docs/v1, got %v", built.newFolders)
+	}
+
+	again, err := built.folderId(uploader, folders, "/Drive/site/docs/v1")
+	if err != nil || again != id || len(built.items) != 2 {
+		t.Errorf("expected the folder to be created once, got %q, %d items, %v", again, len(built.items), err)
+	}
+
+	for _, folderPath := range []string{"/Drive", "/Other/folder", "relative/folder"} {
+		built := &builtBundle{newFolders: map[string]string{}}
+		_, err = built.folderId(uploader, folders, folderPath)
+		if err == nil {
+			t.Errorf("expected %q outside of the parent folder to be refused", folderPath)
+		}
+	}
+}
+
+func TestBulkUploaderBatches(t *testing.T) {
+	uploader := &bulkUploader{maxItems: 2, maxBytes: 100}
+	files := ObjectStorageFiles{{Key: "a", Size: 10}, {Key: "b", Size: 10}, {Key: "c", Size: 60}, {Key: "d", Size: 50}, {Key: "e", Size: 150}}
+
+	bundled, singles := uploader.partition(files, 120)
+	if len(bundled) != 4 || len(singles) != 1 || singles[0].Key != "e" {
+		t.Fatalf("expected e to be uploaded on its own, got %v and %v", bundled, singles)
+	}
+
+	batches := uploader.batches(bundled)
+	keys := [][]string{}
+	for _, batch := range batches {
+		batchKeys := []string{}
+		for _, file := range batch {
+			batchKeys = append(batchKeys, file.Key)
+		}
+		keys = append(keys, batchKeys)
+	}
+	if len(keys) != 3 || len(keys[0]) != 2 || keys[1][0] != "c" || keys[2][0] != "d" {
+		t.Errorf("expected batches [a b] [c] [d], got %v", keys)
+	}
+}
+
+func TestBuiltBundleFees(t *testing.T) {
+	entry := func(dataSize, metadataSize int) bundleEntry {
+		return bundleEntry{file: arfsFile{
+			DataItem:     &arweave.DataItem{Raw: make([]byte, dataSize)},
+			MetadataItem: &arweave.DataItem{Raw: make([]byte, metadataSize)},
+		}}
+	}
+	built := &builtBundle{
+		tx:      &arweave.Transaction{Reward: "1000"},
+		entries: []bundleEntry{entry(90, 10), entry(290, 10), entry(0, 0)},
+	}
+
+	fees := built.fees()
+	if len(fees) != 3 || fees[0] != "250" || fees[1] != "750" || fees[2] != "0" {
+		t.Errorf("expected the reward to be split by size, got %v", fees)
+	}
+
+	built.tx.Reward = "10"
+	built.entries = []bundleEntry{entry(1, 0), entry(1, 0), entry(1, 0)}
+	if fees := built.fees(); fees[0] != "3" || fees[1] != "3" || fees[2] != "4" {
+		t.Errorf("expected the fees to add up to the reward, got %v", fees)
+	}
+}
@@ -2,22 +2,44 @@ package sync
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	gosync "sync"
+	"time"
 )
 
 var globalLock gosync.Mutex
 
+// errCommandTimedOut is returned for commands killed once their timeout
+// elapsed. What the command did up to then is unknown.
+var errCommandTimedOut = errors.New("command timed out")
+
 // ExecCmd executes a command and returns the combined output and error.
 func ExecCmd(cmd string, args ...string) ([]byte, error) {
+	return ExecCmdTimeout(0, cmd, args...)
+}
+
+// ExecCmdTimeout is ExecCmd killing the command once timeout has elapsed.
+// A zero timeout waits for the command indefinitely.
+func ExecCmdTimeout(timeout time.Duration, cmd string, args ...string) ([]byte, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	var combinedOutput bytes.Buffer
-	command := exec.Command(cmd, args...)
+	command := exec.CommandContext(ctx, cmd, args...)
 	command.Stdout = &combinedOutput
 	command.Stderr = &combinedOutput
 
 	err := command.Run()
-	if err != nil {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w after %s\n%s", errCommandTimedOut, timeout, combinedOutput.String())
+	} else if err != nil {
 		// Combine stdout and stderr for non-zero exit codes
 		return nil, fmt.Errorf("command failed: %w\n%s", err, combinedOutput.String())
 	}
@@ -3,27 +3,273 @@ package sync
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Concurrency  int        `yaml:"concurrency"`
-	TmpDirectory string     `yaml:"tmp_directory"`
-	Pipelines    []Pipeline `yaml:"pipelines"`
+	Concurrency       int               `yaml:"concurrency"`
+	TmpDirectory      string            `yaml:"tmp_directory"`
+	StatePath         string            `yaml:"state_path"`
+	StateSaveInterval Duration          `yaml:"state_save_interval"`
+	Gateway           *GatewayConfig    `yaml:"gateway"`
+	Metrics           MetricsConfig     `yaml:"metrics"`
+	Supervision       SupervisionConfig `yaml:"supervision"`
+	Secrets           SecretsConfig     `yaml:"secrets"`
+	Pipelines         []Pipeline        `yaml:"pipelines"`
 }
 
+// LoadConfig loads a single YAML file, every YAML file of a directory or every
+// file matching a glob. Files are merged in lexical order: top level settings
+// may only be set by one file, pipelines are concatenated and the top level
+// "defaults" mapping is applied to every pipeline underneath its own settings.
 func LoadConfig(path string) (Config, error) {
 	cfg := Config{}
-	yamlFile, err := os.ReadFile(path)
+	paths, err := configFiles(path)
 	if err != nil {
-		return cfg, fmt.Errorf("unable to read file path %q: %w", path, err)
+		return cfg, err
 	}
 
-	err = yaml.Unmarshal(yamlFile, &cfg)
+	settings := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	settingSources := map[string]string{}
+	defaults := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	pipelines := []*yaml.Node{}
+	pipelineSources := map[string]string{}
+
+	for _, path := range paths {
+		yamlFile, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("unable to read file path %q: %w", path, err)
+		}
+
+		document := yaml.Node{}
+		err = yaml.Unmarshal(yamlFile, &document)
+		if err != nil {
+			return cfg, fmt.Errorf("unable to parse config yaml %q: %w", path, err)
+		}
+
+		if len(document.Content) == 0 {
+			continue
+		}
+
+		root := document.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return cfg, fmt.Errorf("config %q must be a mapping", path)
+		}
+
+		for i := 0; i < len(root.Content); i += 2 {
+			key, value := root.Content[i], root.Content[i+1]
+
+			if key.Value == "pipelines" {
+				if value.Kind != yaml.SequenceNode {
+					return cfg, fmt.Errorf("pipelines in config %q must be a list", path)
+				}
+
+				for _, pipeline := range value.Content {
+					name := pipelineName(pipeline)
+					if previousPath, exists := pipelineSources[name]; exists && name != "" {
+						return cfg, fmt.Errorf("pipeline name %q is defined in both %q and %q", name, previousPath, path)
+					}
+					pipelineSources[name] = path
+					pipelines = append(pipelines, pipeline)
+				}
+				continue
+			}
+
+			if previousPath, exists := settingSources[key.Value]; exists {
+				return cfg, fmt.Errorf("setting %q is defined in both %q and %q", key.Value, previousPath, path)
+			}
+			settingSources[key.Value] = path
+
+			if key.Value == "defaults" {
+				defaults = value
+				continue
+			}
+
+			settings.Content = append(settings.Content, key, value)
+		}
+	}
+
+	err = settings.Decode(&cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("unable to parse config yaml: %w", err)
+	}
+
+	for _, node := range pipelines {
+		pipeline := Pipeline{}
+		err = mergeYamlNodes(defaults, node).Decode(&pipeline)
+		if err != nil {
+			return cfg, fmt.Errorf("unable to parse pipeline %q from %q: %w", pipelineName(node), pipelineSources[pipelineName(node)], err)
+		}
+		cfg.Pipelines = append(cfg.Pipelines, pipeline)
+	}
+
+	err = cfg.validate()
 	if err != nil {
-		fmt.Errorf("unable to parse config yaml: %w", err)
+		return cfg, fmt.Errorf("invalid config %q: %w", path, err)
 	}
 
 	return cfg, nil
 }
+
+func (cfg Config) stateSaveInterval() time.Duration {
+	if cfg.StateSaveInterval == 0 {
+		return time.Duration(DefaultStateSaveInterval)
+	}
+
+	return time.Duration(cfg.StateSaveInterval)
+}
+
+func (cfg Config) validate() error {
+	if cfg.StateSaveInterval < 0 {
+		return fmt.Errorf("state_save_interval must be positive")
+	}
+
+	names := map[string]bool{}
+	for i, pipeline := range cfg.Pipelines {
+		if pipeline.Name == "" {
+			return fmt.Errorf("pipeline %d has no name", i)
+		}
+
+		if names[pipeline.Name] {
+			return fmt.Errorf("pipeline name %q is used more than once", pipeline.Name)
+		}
+		names[pipeline.Name] = true
+	}
+
+	return nil
+}
+
+// configFiles expands a config path that may be a file, a directory or a glob.
+func configFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err == nil && !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	pattern := path
+	if err == nil && info.IsDir() {
+		pattern = filepath.Join(path, "*")
+	} else if !isGlob(path) {
+		return nil, fmt.Errorf("unable to read file path %q: %w", path, err)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config pattern %q: %w", path, err)
+	}
+
+	paths := []string{}
+	for _, match := range matches {
+		if info, err := os.Stat(match); err != nil || info.IsDir() || !isYamlFile(match) {
+			continue
+		}
+		paths = append(paths, match)
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no config files found at %q", path)
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// configPathMatches reports whether the file name belongs to the config path.
+func configPathMatches(configPath, name string) bool {
+	name = filepath.Clean(name)
+
+	if info, err := os.Stat(configPath); err == nil && info.IsDir() {
+		return filepath.Dir(name) == filepath.Clean(configPath) && isYamlFile(name)
+	}
+
+	if isGlob(configPath) {
+		matched, _ := filepath.Match(filepath.Clean(configPath), name)
+		return matched && isYamlFile(name)
+	}
+
+	return name == filepath.Clean(configPath)
+}
+
+// configDirectories returns the directories that need to be watched for changes to the config path.
+func configDirectories(configPath string) []string {
+	if info, err := os.Stat(configPath); err == nil && info.IsDir() {
+		return []string{configPath}
+	}
+
+	if !isGlob(configPath) {
+		return []string{filepath.Dir(configPath)}
+	}
+
+	directories := []string{}
+	seen := map[string]bool{}
+	matches, _ := filepath.Glob(filepath.Dir(configPath))
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.IsDir() && !seen[match] {
+			seen[match] = true
+			directories = append(directories, match)
+		}
+	}
+
+	return directories
+}
+
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+func isYamlFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func pipelineName(node *yaml.Node) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == "name" {
+			return node.Content[i+1].Value
+		}
+	}
+
+	return ""
+}
+
+// mergeYamlNodes returns override recursively merged on top of base. Mappings
+// are merged key by key, any other value in override replaces the one in base.
+func mergeYamlNodes(base, override *yaml.Node) *yaml.Node {
+	if base == nil || base.Kind != yaml.MappingNode || override.Kind != yaml.MappingNode {
+		return override
+	}
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: override.Tag, Line: override.Line, Column: override.Column}
+	overridden := map[string]*yaml.Node{}
+	for i := 0; i < len(override.Content); i += 2 {
+		overridden[override.Content[i].Value] = override.Content[i+1]
+	}
+
+	for i := 0; i < len(base.Content); i += 2 {
+		key, value := base.Content[i], base.Content[i+1]
+		if overrideValue, exists := overridden[key.Value]; exists {
+			value = mergeYamlNodes(value, overrideValue)
+			delete(overridden, key.Value)
+		}
+		merged.Content = append(merged.Content, key, value)
+	}
+
+	for i := 0; i < len(override.Content); i += 2 {
+		key := override.Content[i]
+		if value, exists := overridden[key.Value]; exists {
+			merged.Content = append(merged.Content, key, value)
+		}
+	}
+
+	return merged
+}
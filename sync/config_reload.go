@@ -0,0 +1,179 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const configReloadDebounce = 500 * time.Millisecond
+
+// reloadSignals merges SIGHUP and config file changes into a single channel.
+// It returns a nil channel when reloading has not been enabled.
+func (s *Synchronizer) reloadSignals(ctx context.Context) (<-chan struct{}, error) {
+	if s.configPath == "" {
+		return nil, nil
+	}
+
+	reloads := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case reloads <- struct{}{}:
+		default:
+		}
+	}
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	var events chan fsnotify.Event
+	var watchErrors chan error
+	var watcher *fsnotify.Watcher
+	if s.watchConfigFile {
+		var err error
+		watcher, err = fsnotify.NewWatcher()
+		if err != nil {
+			signal.Stop(hangups)
+			return nil, err
+		}
+
+		// watch the parent directories so files replaced through a rename,
+		// as most editors and config map updates do, keep being tracked
+		for _, directory := range configDirectories(s.configPath) {
+			err = watcher.Add(directory)
+			if err != nil {
+				signal.Stop(hangups)
+				watcher.Close()
+				return nil, err
+			}
+		}
+
+		events = watcher.Events
+		watchErrors = watcher.Errors
+	}
+
+	go func() {
+		defer signal.Stop(hangups)
+		if watcher != nil {
+			defer watcher.Close()
+		}
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangups:
+				s.logger.Info("received SIGHUP, reloading configuration")
+				notify()
+			case event := <-events:
+				if configPathMatches(s.configPath, event.Name) && !event.Has(fsnotify.Chmod) {
+					debounce = time.After(configReloadDebounce)
+				}
+			case err := <-watchErrors:
+				s.logger.Warn("error watching configuration", "error", err)
+			case <-debounce:
+				debounce = nil
+				s.logger.Info("configuration changed, reloading")
+				notify()
+			}
+		}
+	}()
+
+	return reloads, nil
+}
+
+// reload diffs the pipelines of the new configuration against the running
+// ones, starting new pipelines, stopping removed ones and restarting changed
+// ones. Changes to settings shared by all pipelines restart every pipeline.
+func (s *Synchronizer) reload(ctx context.Context) {
+	config, err := LoadConfig(s.configPath)
+	if err != nil {
+		s.logger.Error("unable to reload configuration, keeping current pipelines", "error", err)
+		return
+	}
+
+	if !sameGlobalSettings(s.config, config) {
+		secrets, err := NewSecretResolver(config.Secrets)
+		if err != nil {
+			s.logger.Error("unable to reload secret providers, keeping current pipelines", "error", err)
+			return
+		}
+
+		state, err := NewStateStore(config.StatePath)
+		if err != nil {
+			s.logger.Error("unable to reload state store, keeping current pipelines", "error", err)
+			return
+		}
+		state.batchSaves(config.stateSaveInterval())
+
+		s.logger.Info("global settings changed, restarting all pipelines")
+		s.stopAllPipelines()
+		s.config = config
+		s.secrets = secrets
+		s.state = state
+		for _, pipeline := range config.Pipelines {
+			s.startPipeline(ctx, pipeline, 0)
+		}
+		return
+	}
+
+	wanted := map[string]Pipeline{}
+	for _, pipeline := range config.Pipelines {
+		wanted[pipeline.Name] = pipeline
+	}
+
+	for name := range s.pipelines {
+		if _, exists := wanted[name]; !exists {
+			s.logger.Info("pipeline removed from configuration", "pipeline", name)
+			s.stopPipeline(name)
+		}
+	}
+
+	for _, previous := range s.config.Pipelines {
+		if _, exists := wanted[previous.Name]; !exists {
+			s.deleteHealth(previous.Name)
+			s.metrics.DeleteGauges("pipeline", previous.Name)
+		}
+	}
+
+	for _, pipeline := range config.Pipelines {
+		previous, exists := s.findPipeline(pipeline.Name)
+		if exists && reflect.DeepEqual(previous, pipeline) {
+			continue
+		}
+
+		if exists {
+			s.logger.Info("pipeline configuration changed, restarting", "pipeline", pipeline.Name)
+			s.stopPipeline(pipeline.Name)
+		} else {
+			s.logger.Info("pipeline added to configuration", "pipeline", pipeline.Name)
+		}
+
+		s.startPipeline(ctx, pipeline, 0)
+	}
+
+	s.config = config
+}
+
+func (s *Synchronizer) findPipeline(name string) (Pipeline, bool) {
+	for _, pipeline := range s.config.Pipelines {
+		if pipeline.Name == name {
+			return pipeline, true
+		}
+	}
+
+	return Pipeline{}, false
+}
+
+func sameGlobalSettings(a, b Config) bool {
+	a.Pipelines = nil
+	b.Pipelines = nil
+
+	return reflect.DeepEqual(a, b)
+}
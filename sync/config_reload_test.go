@@ -0,0 +1,80 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/the-singularity-labs/cornelius/log"
+)
+
+func TestReload(t *testing.T) {
+	configPath := filepath.Join(writeConfigFiles(t, map[string]string{
+		"config.yaml": "pipelines: [{name: a}, {name: b}, {name: d}]",
+	}), "config.yaml")
+	writeConfig := func(contents string) {
+		t.Helper()
+		err := os.WriteFile(configPath, []byte(contents), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(log.NewTextLogger(slog.LevelError), "", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.EnableReload(configPath, false)
+
+	// the pipelines fail right away for lack of a bucket, their results are
+	// never collected
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, pipeline := range config.Pipelines {
+		s.startPipeline(ctx, pipeline, 0)
+	}
+	started := map[string]*runningPipeline{}
+	for name, running := range s.pipelines {
+		started[name] = running
+	}
+
+	writeConfig("pipelines: [{name: a}, {name: b, on_oversize: skip}, {name: c}]")
+	s.reload(ctx)
+
+	if s.pipelines["a"] != started["a"] {
+		t.Error("expected the unchanged pipeline to keep running")
+	}
+	if s.pipelines["b"] == started["b"] || s.pipelines["b"] == nil || s.pipelines["b"].pipeline.OnOversize != OversizeSkip || !started["b"].stopped {
+		t.Error("expected the changed pipeline to be restarted with its new settings")
+	}
+	if s.pipelines["c"] == nil {
+		t.Error("expected the added pipeline to be started")
+	}
+	if _, exists := s.pipelines["d"]; exists || !started["d"].stopped {
+		t.Error("expected the removed pipeline to be stopped")
+	}
+	if _, exists := s.health["d"]; exists {
+		t.Error("expected the health of the removed pipeline to be forgotten")
+	}
+
+	// invalid configurations keep the pipelines running
+	running := s.pipelines["a"]
+	writeConfig("pipelines: [{name: a}, {name: a}]")
+	s.reload(ctx)
+	if s.pipelines["a"] != running || len(s.pipelines) != 3 {
+		t.Errorf("expected an invalid configuration to be ignored, got %v", s.pipelines)
+	}
+
+	// changed global settings restart every pipeline
+	writeConfig("concurrency: 4\npipelines: [{name: a}, {name: b, on_oversize: skip}, {name: c}]")
+	s.reload(ctx)
+	if s.config.Concurrency != 4 || len(s.pipelines) != 3 || s.pipelines["a"] == running || !running.stopped {
+		t.Errorf("expected every pipeline to be restarted, got %v", s.pipelines)
+	}
+}
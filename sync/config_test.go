@@ -0,0 +1,131 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, contents := range files {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(dir, name), []byte(contents), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestLoadConfigDirectory(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"00-global.yaml": `
+concurrency: 2
+defaults:
+  on_oversize: split
+  filters:
+    exclude: ["*.log"]
+    min_size: 1
+`,
+		"10-site.yml": `
+pipelines:
+  - name: site
+    filters:
+      min_size: 10
+`,
+		"20-backups.yaml": `
+pipelines:
+  - name: backups
+    on_oversize: skip
+`,
+		"notes.txt": "not: yaml",
+	})
+
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Concurrency != 2 {
+		t.Errorf("expected concurrency 2, got %d", cfg.Concurrency)
+	}
+	if len(cfg.Pipelines) != 2 || cfg.Pipelines[0].Name != "site" || cfg.Pipelines[1].Name != "backups" {
+		t.Fatalf("expected the pipelines in file order, got %+v", cfg.Pipelines)
+	}
+
+	// defaults are merged key by key underneath the pipeline's own settings
+	site := cfg.Pipelines[0]
+	if site.OnOversize != OversizeSplit || site.Filters.MinSize != 10 || len(site.Filters.Exclude) != 1 || site.Filters.Exclude[0] != "*.log" {
+		t.Errorf("expected site to merge the defaults, got %+v", site)
+	}
+	backups := cfg.Pipelines[1]
+	if backups.OnOversize != OversizeSkip || backups.Filters.MinSize != 1 {
+		t.Errorf("expected backups to override on_oversize only, got %+v", backups)
+	}
+}
+
+func TestLoadConfigGlob(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"conf/site.yaml":    "pipelines: [{name: site}]",
+		"conf/backups.yaml": "pipelines: [{name: backups}]",
+		"conf/site.yml":     "pipelines: [{name: other}]",
+	})
+
+	cfg, err := LoadConfig(filepath.Join(dir, "conf", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Pipelines) != 2 || cfg.Pipelines[0].Name != "backups" || cfg.Pipelines[1].Name != "site" {
+		t.Errorf("expected the pipelines of the matched files, got %+v", cfg.Pipelines)
+	}
+
+	_, err = LoadConfig(filepath.Join(dir, "conf", "*.json"))
+	if err == nil || !strings.Contains(err.Error(), "no config files found") {
+		t.Errorf("expected a glob without matches to fail, got %v", err)
+	}
+}
+
+func TestLoadConfigConflicts(t *testing.T) {
+	for name, files := range map[string]map[string]string{
+		"setting":  {"a.yaml": "concurrency: 1", "b.yaml": "concurrency: 2"},
+		"defaults": {"a.yaml": "defaults: {on_oversize: skip}", "b.yaml": "defaults: {on_oversize: split}"},
+		"pipeline": {"a.yaml": "pipelines: [{name: site}]", "b.yaml": "pipelines: [{name: site}]"},
+	} {
+		_, err := LoadConfig(writeConfigFiles(t, files))
+		if err == nil || !strings.Contains(err.Error(), "is defined in both") {
+			t.Errorf("expected a %s defined twice to be rejected, got %v", name, err)
+		}
+	}
+}
+
+func TestConfigPathMatches(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{"conf/site.yaml": ""})
+
+	for _, match := range []struct {
+		configPath string
+		name       string
+		matches    bool
+	}{
+		{"./conf/*.yaml", "conf/site.yaml", true},
+		{"conf/*.yaml", "./conf/site.yaml", true},
+		{"conf/*.yaml", "conf/site.txt", false},
+		{"conf/*.yaml", "other/site.yaml", false},
+		{filepath.Join(dir, "conf"), filepath.Join(dir, "conf", "new.yml"), true},
+		{filepath.Join(dir, "conf"), filepath.Join(dir, "conf", "sub", "new.yml"), false},
+		{filepath.Join(dir, "conf") + "/", filepath.Join(dir, "conf", "notes.txt"), false},
+		{"./config.yaml", "config.yaml", true},
+		{"config.yaml", "other.yaml", false},
+	} {
+		if matches := configPathMatches(match.configPath, match.name); matches != match.matches {
+			t.Errorf("expected %s matching %s to be %v", match.configPath, match.name, match.matches)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultConfirmations = 10
+	DefaultDropAfter     = Duration(time.Hour)
+)
+
+// ConfirmationConfig enables tracking of the transactions of uploaded files
+// until they reached Confirmations blocks. Transactions the gateway still
+// does not know DropAfter their upload are considered dropped and the object
+// is uploaded again.
+type ConfirmationConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	Confirmations int      `yaml:"confirmations"`
+	DropAfter     Duration `yaml:"drop_after"`
+}
+
+type confirmationTracker struct {
+	confirmations int
+	dropAfter     time.Duration
+}
+
+func newConfirmationTracker(config ConfirmationConfig) (*confirmationTracker, error) {
+	confirmations := config.Confirmations
+	if confirmations == 0 {
+		confirmations = DefaultConfirmations
+	} else if confirmations < 0 {
+		return nil, fmt.Errorf("confirmations must be positive")
+	}
+
+	dropAfter := config.DropAfter
+	if dropAfter == 0 {
+		dropAfter = DefaultDropAfter
+	}
+
+	return &confirmationTracker{
+		confirmations: confirmations,
+		dropAfter:     time.Duration(dropAfter),
+	}, nil
+}
+
+// txIds returns the transactions an upload depends on. Files of a bundle are
+// settled with the bundle transaction.
+func (fileState FileState) txIds() []string {
+	if fileState.BundledIn != "" {
+		return []string{fileState.BundledIn}
+	}
+
+	txIds := []string{}
+	for _, txId := range []string{fileState.DataTxId, fileState.MetadataTxId} {
+		if txId != "" {
+			txIds = append(txIds, txId)
+		}
+	}
+
+	return txIds
+}
+
+// trackConfirmations updates the confirmations of every unconfirmed upload
+// of the pipeline and flags those whose transactions were dropped.
+func (run *pipelineRun) trackConfirmations(ctx context.Context) error {
+	droppedBundles := map[string]bool{}
+	defer func() {
+		run.dropBundles(droppedBundles)
+	}()
+
+	for _, fileState := range run.state.Files(run.pipeline.Name) {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if fileState.UploadedAt.IsZero() || !fileState.ConfirmedAt.IsZero() || fileState.Dropped || len(fileState.txIds()) == 0 {
+			continue
+		}
+
+		logger := run.logger.With("object", fileState.Key)
+		confirmations := -1
+		dropped := false
+		for _, txId := range fileState.txIds() {
+			status, err := run.gateway.Status(ctx, txId)
+			if err != nil {
+				logger.Warn("unable to get transaction status", "tx_id", txId, "error", err)
+				confirmations = -1
+				break
+			}
+
+			if !status.Found && time.Since(fileState.UploadedAt) > run.confirmations.dropAfter {
+				logger.Warn("transaction dropped, object will be uploaded again", "tx_id", txId, "uploaded_at", fileState.UploadedAt)
+				dropped = true
+			}
+
+			if confirmations == -1 || status.Confirmations < confirmations {
+				confirmations = status.Confirmations
+			}
+		}
+
+		if confirmations == -1 {
+			continue
+		}
+
+		if dropped && fileState.BundledIn != "" {
+			droppedBundles[fileState.BundledIn] = true
+		}
+
+		confirmed := confirmations >= run.confirmations.confirmations
+		if confirmed {
+			logger.Info("upload confirmed", "confirmations", confirmations, "tx_ids", fileState.txIds())
+		}
+
+		err := run.state.UpdateFile(run.pipeline.Name, fileState.Key, func(fileState *FileState) {
+			fileState.Confirmations = confirmations
+			fileState.Dropped = dropped
+			if confirmed {
+				fileState.ConfirmedAt = time.Now()
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("unable to record confirmations of %q: %w", fileState.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// dropBundles forgets the folders created in dropped bundles along with the
+// pending entries of their files, so neither re-uploads nor the manifest
+// refer to entities that never made it on chain. Files later bundles placed
+// in those folders are orphaned and flagged for upload as well.
+func (run *pipelineRun) dropBundles(bundleTxIds map[string]bool) {
+	if len(bundleTxIds) == 0 {
+		return
+	}
+
+	droppedFolders := map[string]string{}
+	inDroppedFolder := func(drivePath string) bool {
+		for folderPath := path.Dir(drivePath); folderPath != "/" && folderPath != "."; folderPath = path.Dir(folderPath) {
+			if _, dropped := droppedFolders[folderPath]; dropped {
+				return true
+			}
+		}
+		return false
+	}
+
+	err := run.state.UpdatePipeline(run.pipeline.Name, func(pipelineState *PipelineState) {
+		for folderPath, folderState := range pipelineState.Folders {
+			if bundleTxIds[folderState.BundledIn] {
+				droppedFolders[folderPath] = folderState.EntityId
+			}
+		}
+		// folders later bundles created inside dropped folders are orphaned
+		for folderPath, folderState := range pipelineState.Folders {
+			if inDroppedFolder(folderPath) {
+				droppedFolders[folderPath] = folderState.EntityId
+			}
+		}
+		for folderPath := range droppedFolders {
+			delete(pipelineState.Folders, folderPath)
+		}
+	})
+	if err != nil {
+		run.logger.Warn("unable to forget folders of dropped bundles", "error", err)
+	}
+
+	droppedPaths := map[string]bool{}
+	for _, fileState := range run.state.Files(run.pipeline.Name) {
+		if fileState.DrivePath == "" {
+			continue
+		} else if bundleTxIds[fileState.BundledIn] {
+			droppedPaths[fileState.DrivePath] = true
+			continue
+		} else if fileState.Dropped || !inDroppedFolder(fileState.DrivePath) {
+			continue
+		}
+
+		run.logger.Warn("folder of upload was dropped, object will be uploaded again", "object", fileState.Key, "bundled_in", fileState.BundledIn)
+		droppedPaths[fileState.DrivePath] = true
+		err := run.state.UpdateFile(run.pipeline.Name, fileState.Key, func(fileState *FileState) {
+			fileState.Dropped = true
+		})
+		if err != nil {
+			run.logger.Warn("unable to flag orphaned upload", "object", fileState.Key, "error", err)
+		}
+	}
+
+	pending := ArdriveFiles{}
+	for _, pendingFile := range run.pending {
+		if pendingFile.EntityType == arfsEntityFolder && droppedFolders[pendingFile.Path] == pendingFile.EntityId {
+			continue
+		} else if pendingFile.EntityType == arfsEntityFile && droppedPaths[pendingFile.Path] {
+			continue
+		}
+		pending = append(pending, pendingFile)
+	}
+	run.pending = pending
+
+	txIds := []string{}
+	for txId := range bundleTxIds {
+		txIds = append(txIds, txId)
+	}
+	sort.Strings(txIds)
+	run.logger.Info("forgot entities of dropped bundles", "bundle_tx_ids", strings.Join(txIds, ", "), "folders", len(droppedFolders), "files", len(droppedPaths))
+}
+
+// withDropped adds objects whose upload was dropped to the files to sync.
+func (run *pipelineRun) withDropped(delta, objectStorageFiles ObjectStorageFiles) ObjectStorageFiles {
+	dropped := map[string]bool{}
+	for _, fileState := range run.state.Files(run.pipeline.Name) {
+		if fileState.Dropped {
+			dropped[fileState.Key] = true
+		}
+	}
+
+	for _, objectStorageFile := range delta {
+		delete(dropped, objectStorageFile.Key)
+	}
+
+	for _, objectStorageFile := range objectStorageFiles {
+		if dropped[objectStorageFile.Key] {
+			delta = append(delta, objectStorageFile)
+		}
+	}
+
+	return delta
+}
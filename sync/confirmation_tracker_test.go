@@ -0,0 +1,141 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/the-singularity-labs/cornelius/arweave"
+	"github.com/the-singularity-labs/cornelius/log"
+)
+
+func TestTrackConfirmationsDropsBundles(t *testing.T) {
+	// bundle-a is unknown to the gateway, bundle-b settled and bundle-c was
+	// posted too recently to be dropped
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tx/bundle-b/status":
+			w.Write([]byte(`{"number_of_confirmations": 12}`))
+		case "/graphql":
+			w.Write([]byte(`{"data": {"transactions": {"edges": []}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	state, err := NewStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pipeline := Pipeline{Name: "site"}
+	uploadedAt := time.Now().Add(-2 * time.Hour)
+	err = state.UpdatePipeline(pipeline.Name, func(pipelineState *PipelineState) {
+		pipelineState.Folders["/drive/docs"] = &FolderState{EntityId: "docs-folder", BundledIn: "bundle-a"}
+		pipelineState.Folders["/drive/docs/sub"] = &FolderState{EntityId: "sub-folder", BundledIn: "bundle-b"}
+		pipelineState.Folders["/drive/img"] = &FolderState{EntityId: "img-folder", BundledIn: "bundle-b"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, fileState := range map[string]FileState{
+		"docs/a.txt":     {BundledIn: "bundle-a", UploadedAt: uploadedAt},
+		"docs/sub/b.txt": {BundledIn: "bundle-b", UploadedAt: uploadedAt},
+		"img/c.png":      {BundledIn: "bundle-b", UploadedAt: uploadedAt},
+		"d.txt":          {BundledIn: "bundle-c", UploadedAt: time.Now()},
+	} {
+		err = state.UpdateFile(pipeline.Name, key, func(state *FileState) {
+			*state = fileState
+			state.Key = key
+			state.EntityId = key + "-entity"
+			state.DrivePath = "/drive/" + key
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	confirmations, err := newConfirmationTracker(ConfirmationConfig{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := &pipelineRun{
+		pipeline:      pipeline,
+		logger:        log.NewTextLogger(slog.LevelError),
+		state:         state,
+		gateway:       arweave.NewGateway(server.URL, server.URL, server.URL+"/graphql", time.Minute, time.Minute),
+		confirmations: confirmations,
+	}
+	run.restorePending()
+
+	err = run.trackConfirmations(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// files of the dropped bundle and those placed in its folders by later
+	// bundles are uploaded again
+	objectStorageFiles := ObjectStorageFiles{{Key: "docs/a.txt"}, {Key: "docs/sub/b.txt"}, {Key: "img/c.png"}, {Key: "d.txt"}}
+	dropped := []string{}
+	for _, objectStorageFile := range run.withDropped(nil, objectStorageFiles) {
+		dropped = append(dropped, objectStorageFile.Key)
+	}
+	sort.Strings(dropped)
+	if strings.Join(dropped, ",") != "docs/a.txt,docs/sub/b.txt" {
+		t.Errorf("expected docs/a.txt and docs/sub/b.txt to be dropped, got %v", dropped)
+	}
+
+	if fileState, _ := state.File(pipeline.Name, "img/c.png"); fileState.Confirmations != 12 || fileState.ConfirmedAt.IsZero() {
+		t.Errorf("expected img/c.png to be confirmed, got %+v", fileState)
+	}
+
+	folders := state.Pipeline(pipeline.Name).Folders
+	if len(folders) != 1 || folders["/drive/img"] == nil {
+		t.Errorf("expected only the folders of dropped bundles to be forgotten, got %v", folders)
+	}
+
+	pending := []string{}
+	for _, pendingFile := range run.pending {
+		pending = append(pending, pendingFile.Path)
+	}
+	sort.Strings(pending)
+	if strings.Join(pending, ",") != "/drive/d.txt,/drive/img,/drive/img/c.png" {
+		t.Errorf("expected the pending entries of dropped bundles to be removed, got %v", pending)
+	}
+}
+
+func TestMarkUploadedRestartsTracking(t *testing.T) {
+	fileState := FileState{
+		Key:           "index.html",
+		DrivePath:     "/drive/index.html",
+		UploadedAt:    time.Now().Add(-2 * time.Hour),
+		Confirmations: 3,
+		ConfirmedAt:   time.Now().Add(-time.Hour),
+		Dropped:       true,
+		Verification:  VerificationFailed,
+		VerifiedAt:    time.Now().Add(-time.Hour),
+	}
+
+	uploadedAt := time.Now()
+	fileState.markUploaded(uploadedAt)
+
+	if !fileState.UploadedAt.Equal(uploadedAt) || fileState.Resubmissions != 1 {
+		t.Errorf("expected a resubmission at %v, got %+v", uploadedAt, fileState)
+	}
+	if fileState.DrivePath != "" || fileState.Confirmations != 0 || !fileState.ConfirmedAt.IsZero() || fileState.Dropped {
+		t.Errorf("expected the confirmations to be reset, got %+v", fileState)
+	}
+	if fileState.Verification != "" || !fileState.VerifiedAt.IsZero() {
+		t.Errorf("expected the verification to be reset, got %+v", fileState)
+	}
+
+	fileState.markUploaded(time.Now())
+	if fileState.Resubmissions != 1 {
+		t.Errorf("expected only uploads of dropped files to count as resubmissions, got %d", fileState.Resubmissions)
+	}
+}
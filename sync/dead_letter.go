@@ -0,0 +1,96 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// deadLetter records an object that failed after all retries so later
+// iterations skip it.
+func (run *pipelineRun) deadLetter(object ObjectStorageFile, class string, err error) error {
+	attempts := 1
+	var retryErr *retryError
+	if errors.As(err, &retryErr) {
+		attempts = retryErr.attempts
+		err = retryErr.err
+	}
+
+	run.logger.Warn("dead-lettering object, skipping it until it is retried", "object", object.Key, "reason", class, "attempts", attempts)
+
+	err = run.state.UpdatePipeline(run.pipeline.Name, func(pipelineState *PipelineState) {
+		pipelineState.DeadLetters[object.Key] = &DeadLetter{
+			Key:       object.Key,
+			Reason:    class,
+			Attempts:  attempts,
+			LastError: err.Error(),
+			FailedAt:  time.Now(),
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record dead letter of %q: %w", object.Key, err)
+	}
+
+	return nil
+}
+
+// withoutDeadLetters removes dead-lettered objects from the files to sync.
+func (run *pipelineRun) withoutDeadLetters(delta ObjectStorageFiles) (ObjectStorageFiles, SkippedObjects) {
+	deadLetters := map[string]bool{}
+	for _, deadLetter := range run.state.DeadLetters(run.pipeline.Name) {
+		deadLetters[deadLetter.Key] = true
+	}
+
+	if len(deadLetters) == 0 {
+		return delta, nil
+	}
+
+	results := ObjectStorageFiles{}
+	skipped := SkippedObjects{}
+	for _, objectStorageFile := range delta {
+		if deadLetters[objectStorageFile.Key] {
+			skipped = append(skipped, SkippedObject{Key: objectStorageFile.Key, Reason: "dead-lettered"})
+			continue
+		}
+		results = append(results, objectStorageFile)
+	}
+
+	return results, skipped
+}
+
+// RetryDeadLetter removes an object from the dead letters of the pipeline so
+// it is uploaded again on the next iteration.
+func (store *StateStore) RetryDeadLetter(pipelineName, key string) error {
+	if store == nil {
+		return fmt.Errorf("no state_path configured")
+	}
+
+	found := false
+	err := store.UpdatePipeline(pipelineName, func(pipelineState *PipelineState) {
+		_, found = pipelineState.DeadLetters[key]
+		delete(pipelineState.DeadLetters, key)
+	})
+	if err != nil {
+		return err
+	} else if !found {
+		return fmt.Errorf("object %q of pipeline %q is not dead-lettered", key, pipelineName)
+	}
+
+	return nil
+}
+
+// ClearDeadLetters removes every dead letter of the pipeline and returns how
+// many there were.
+func (store *StateStore) ClearDeadLetters(pipelineName string) (int, error) {
+	if store == nil {
+		return 0, fmt.Errorf("no state_path configured")
+	}
+
+	cleared := 0
+	err := store.UpdatePipeline(pipelineName, func(pipelineState *PipelineState) {
+		cleared = len(pipelineState.DeadLetters)
+		pipelineState.DeadLetters = map[string]*DeadLetter{}
+	})
+
+	return cleared, err
+}
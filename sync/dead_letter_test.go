@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/the-singularity-labs/cornelius/log"
+)
+
+func TestDeadLetters(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	state, err := NewStateStore(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := &pipelineRun{pipeline: Pipeline{Name: "site"}, logger: log.NewTextLogger(slog.LevelError), state: state}
+
+	err = run.deadLetter(ObjectStorageFile{Key: "a.txt"}, ErrorPermanent, &retryError{attempts: 3, err: errors.New("invalid drive id")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = run.deadLetter(ObjectStorageFile{Key: "b.txt"}, ErrorInsufficientFunds, errors.New("insufficient funds"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadLetters := state.DeadLetters("site")
+	if len(deadLetters) != 2 || deadLetters[0].Attempts != 3 || deadLetters[0].LastError != "invalid drive id" || deadLetters[1].Reason != ErrorInsufficientFunds {
+		t.Fatalf("expected both objects to be dead-lettered, got %+v", deadLetters)
+	}
+
+	delta, skipped := run.withoutDeadLetters(ObjectStorageFiles{{Key: "a.txt"}, {Key: "b.txt"}, {Key: "c.txt"}})
+	if len(delta) != 1 || delta[0].Key != "c.txt" || len(skipped) != 2 || skipped[0].Reason != "dead-lettered" {
+		t.Errorf("expected dead-lettered objects to be skipped, got %v, %v", delta, skipped)
+	}
+
+	// the retry and dead-letters commands change the state from another
+	// process, the running pipeline picks the changes up
+	command, err := NewStateStore(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = command.RetryDeadLetter("site", "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = command.RetryDeadLetter("site", "c.txt")
+	if err == nil {
+		t.Error("expected retrying an object that is not dead-lettered to fail")
+	}
+
+	delta, _ = run.withoutDeadLetters(ObjectStorageFiles{{Key: "a.txt"}, {Key: "b.txt"}})
+	if len(delta) != 1 || delta[0].Key != "a.txt" {
+		t.Errorf("expected the retried object to be synced again, got %v", delta)
+	}
+
+	cleared, err := command.ClearDeadLetters("site")
+	if err != nil || cleared != 1 {
+		t.Errorf("expected one dead letter to be cleared, got %d, %v", cleared, err)
+	}
+	if deadLetters := state.DeadLetters("site"); len(deadLetters) != 0 {
+		t.Errorf("expected no dead letters left, got %+v", deadLetters)
+	}
+}
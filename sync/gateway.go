@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultGatewayURL = "https://arweave.net"
+
+	// DefaultGatewayRequestTimeout bounds the requests Cornelius sends to the
+	// gateway itself when no timeout is configured. ardrive-cli commands,
+	// e.g. listing large drives, are not limited by default.
+	DefaultGatewayRequestTimeout = 2 * time.Minute
+)
+
+// GatewayConfig selects the Arweave gateway reads and uploads go through.
+// UploadURL and GraphqlURL default to URL, Timeout applies to reads and
+// UploadTimeout to uploads, where zero means no limit.
+type GatewayConfig struct {
+	URL           string   `yaml:"url"`
+	UploadURL     string   `yaml:"upload_url"`
+	GraphqlURL    string   `yaml:"graphql_url"`
+	Timeout       Duration `yaml:"timeout"`
+	UploadTimeout Duration `yaml:"upload_timeout"`
+}
+
+// gatewayConfig returns the gateway of the pipeline, falling back to the
+// top level gateway setting by setting and then to the defaults.
+func (cfg Config) gatewayConfig(pipeline Pipeline) (GatewayConfig, error) {
+	gateway := GatewayConfig{}
+	for _, layer := range []*GatewayConfig{cfg.Gateway, pipeline.Gateway} {
+		if layer == nil {
+			continue
+		}
+
+		if layer.URL != "" {
+			gateway.URL = layer.URL
+		}
+		if layer.UploadURL != "" {
+			gateway.UploadURL = layer.UploadURL
+		}
+		if layer.GraphqlURL != "" {
+			gateway.GraphqlURL = layer.GraphqlURL
+		}
+		if layer.Timeout != 0 {
+			gateway.Timeout = layer.Timeout
+		}
+		if layer.UploadTimeout != 0 {
+			gateway.UploadTimeout = layer.UploadTimeout
+		}
+	}
+
+	if gateway.URL == "" {
+		gateway.URL = DefaultGatewayURL
+	}
+	gateway.URL = strings.TrimSuffix(gateway.URL, "/")
+
+	if gateway.UploadURL == "" {
+		gateway.UploadURL = gateway.URL
+	}
+	gateway.UploadURL = strings.TrimSuffix(gateway.UploadURL, "/")
+
+	if gateway.GraphqlURL == "" {
+		gateway.GraphqlURL = gateway.URL + "/graphql"
+	}
+
+	for _, endpoint := range []string{gateway.URL, gateway.UploadURL, gateway.GraphqlURL} {
+		parsed, err := url.Parse(endpoint)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return gateway, fmt.Errorf("%q is not a valid gateway url", endpoint)
+		}
+	}
+
+	return gateway, nil
+}
+
+// requestTimeout is the timeout of reads sent to the gateway directly.
+func (gateway GatewayConfig) requestTimeout() time.Duration {
+	if gateway.Timeout == 0 {
+		return DefaultGatewayRequestTimeout
+	}
+
+	return time.Duration(gateway.Timeout)
+}
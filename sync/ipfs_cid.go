@@ -0,0 +1,143 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// IpfsAddTag is the ArFS tag holding the IPFS CID of a file's data.
+const IpfsAddTag = "IPFS-Add"
+
+// The defaults of `ipfs add --cid-version=1`: 256KiB chunks stored as raw
+// leaves, combined in a balanced dag-pb tree of at most 174 links per node.
+const (
+	ipfsChunkSize    = 256 * 1024
+	ipfsMaxLinks     = 174
+	ipfsCodecRaw     = 0x55
+	ipfsCodecDagPb   = 0x70
+	ipfsSha256       = 0x12
+	ipfsUnixfsFile   = 2
+	ipfsMultibase32  = "b"
+	ipfsSha256Length = 32
+)
+
+type ipfsNode struct {
+	cid      []byte
+	fileSize uint64
+	tsize    uint64
+}
+
+// fileIpfsCid computes the CIDv1 an IPFS node would assign to the file.
+func fileIpfsCid(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to open %q: %w", path, err)
+	}
+	defer file.Close()
+
+	leaves := []ipfsNode{}
+	chunk := make([]byte, ipfsChunkSize)
+	for {
+		n, err := io.ReadFull(file, chunk)
+		if n > 0 || len(leaves) == 0 {
+			leaves = append(leaves, ipfsRawLeaf(chunk[:n]))
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return "", fmt.Errorf("unable to read %q: %w", path, err)
+		}
+	}
+
+	root := ipfsBalancedRoot(leaves)
+
+	return ipfsMultibase32 + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(root.cid)), nil
+}
+
+func ipfsRawLeaf(data []byte) ipfsNode {
+	return ipfsNode{
+		cid:      ipfsCid(ipfsCodecRaw, data),
+		fileSize: uint64(len(data)),
+		tsize:    uint64(len(data)),
+	}
+}
+
+// ipfsBalancedRoot groups the nodes level by level until a single root remains.
+func ipfsBalancedRoot(nodes []ipfsNode) ipfsNode {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+
+	for {
+		parents := []ipfsNode{}
+		for start := 0; start < len(nodes); start += ipfsMaxLinks {
+			end := min(start+ipfsMaxLinks, len(nodes))
+			parents = append(parents, ipfsFileNode(nodes[start:end]))
+		}
+
+		if len(parents) == 1 {
+			return parents[0]
+		}
+		nodes = parents
+	}
+}
+
+func ipfsFileNode(children []ipfsNode) ipfsNode {
+	unixfs := []byte{}
+	fileSize := uint64(0)
+	for _, child := range children {
+		fileSize += child.fileSize
+	}
+	unixfs = protoVarint(unixfs, 1, ipfsUnixfsFile)
+	unixfs = protoVarint(unixfs, 3, fileSize)
+	for _, child := range children {
+		unixfs = protoVarint(unixfs, 4, child.fileSize)
+	}
+
+	// dag-pb encodes links ahead of the data
+	node := []byte{}
+	tsize := uint64(0)
+	for _, child := range children {
+		link := []byte{}
+		link = protoBytes(link, 1, child.cid)
+		link = protoBytes(link, 2, nil)
+		link = protoVarint(link, 3, child.tsize)
+		node = protoBytes(node, 2, link)
+		tsize += child.tsize
+	}
+	node = protoBytes(node, 1, unixfs)
+
+	return ipfsNode{
+		cid:      ipfsCid(ipfsCodecDagPb, node),
+		fileSize: fileSize,
+		tsize:    tsize + uint64(len(node)),
+	}
+}
+
+func ipfsCid(codec uint64, block []byte) []byte {
+	digest := sha256.Sum256(block)
+
+	cid := binary.AppendUvarint(nil, 1)
+	cid = binary.AppendUvarint(cid, codec)
+	cid = binary.AppendUvarint(cid, ipfsSha256)
+	cid = binary.AppendUvarint(cid, ipfsSha256Length)
+
+	return append(cid, digest[:]...)
+}
+
+func protoVarint(buf []byte, field int, value uint64) []byte {
+	buf = binary.AppendUvarint(buf, uint64(field<<3))
+	return binary.AppendUvarint(buf, value)
+}
+
+func protoBytes(buf []byte, field int, value []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(field<<3|2))
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
@@ -0,0 +1,64 @@
+package sync
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileIpfsCid(t *testing.T) {
+	pattern := make([]byte, 1024*1024)
+	for i := range pattern {
+		pattern[i] = byte(i % 251)
+	}
+
+	for _, test := range []struct {
+		name     string
+		contents []byte
+		cid      string
+	}{
+		// `ipfs add --cid-version=1` of files fitting a single chunk yields
+		// the raw leaf itself
+		{"empty", []byte{}, "bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku"},
+		{"hello world", []byte("hello world"), "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e"},
+		{"one chunk", pattern[:ipfsChunkSize], "bafkreibruh455iawsviqslif5c7uurdcfdemh22mtnytyzvnzn75kpejxy"},
+		// larger files are a dag-pb node linking their chunks
+		{"zeros", make([]byte, 1024*1024), "bafybeiggzq4ryi7hscq5hzvzcnk4urnxt3asp37dhgvnjilf7exskximla"},
+		{"pattern", pattern, "bafybeiedpcapwld4tkgtzwahfofgn4wex5ryysf4se6hwpmlrsh4ntnrau"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file")
+			err := os.WriteFile(path, test.contents, 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cid, err := fileIpfsCid(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cid != test.cid {
+				t.Errorf("expected cid %s, got %s", test.cid, cid)
+			}
+		})
+	}
+}
+
+func TestIpfsBalancedRoot(t *testing.T) {
+	leaves := make([]ipfsNode, ipfsMaxLinks+1)
+	for i := range leaves {
+		leaves[i] = ipfsRawLeaf([]byte{byte(i)})
+	}
+
+	root := ipfsBalancedRoot(leaves)
+	if root.fileSize != uint64(len(leaves)) {
+		t.Errorf("expected file size %d, got %d", len(leaves), root.fileSize)
+	}
+
+	// more leaves than fit a node add a level of two nodes
+	expected := ipfsFileNode([]ipfsNode{ipfsFileNode(leaves[:ipfsMaxLinks]), ipfsFileNode(leaves[ipfsMaxLinks:])})
+	if !bytes.Equal(root.cid, expected.cid) {
+		t.Error("expected the leaves to be split over two nodes below the root")
+	}
+}
@@ -5,9 +5,11 @@ import (
 )
 
 type LocalFile struct {
-	Dir      string
-	Path     string
-	Mimetype string
+	Dir            string
+	Path           string
+	Mimetype       string
+	Sha256         string
+	CustomMetadata ArfsCustomMetadata
 }
 
 func (lf LocalFile) Filename() string {
@@ -0,0 +1,124 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	DefaultManifestName  = "DriveManifest.json"
+	DefaultManifestIndex = "index.html"
+	ManifestContentType  = "application/x.arweave-manifest+json"
+
+	manifestType    = "arweave/paths"
+	manifestVersion = "0.2.0"
+)
+
+// ManifestConfig configures the Arweave path manifest generated for the
+// parent folder of a pipeline, e.g. to serve a static site.
+type ManifestConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Name     string `yaml:"name"`
+	Index    string `yaml:"index"`
+	Fallback string `yaml:"fallback"`
+}
+
+type arweaveManifest struct {
+	Manifest string                         `json:"manifest"`
+	Version  string                         `json:"version"`
+	Index    *arweaveManifestIndex          `json:"index,omitempty"`
+	Fallback *arweaveManifestPath           `json:"fallback,omitempty"`
+	Paths    map[string]arweaveManifestPath `json:"paths"`
+}
+
+type arweaveManifestIndex struct {
+	Path string `json:"path"`
+}
+
+type arweaveManifestPath struct {
+	Id string `json:"id"`
+}
+
+// manifestConfig returns the manifest settings of the pipeline, taking the
+// legacy enable_manifest flag into account.
+func (pipeline Pipeline) manifestConfig() ManifestConfig {
+	config := ManifestConfig{}
+	if pipeline.Manifest != nil {
+		config = *pipeline.Manifest
+	} else if pipeline.EnableManifest {
+		config.Enabled = true
+	}
+
+	if config.Name == "" {
+		config.Name = DefaultManifestName
+	}
+
+	if config.Index == "" {
+		config.Index = DefaultManifestIndex
+	}
+
+	return config
+}
+
+// buildManifest maps every file below parentPath, including nested folders,
+// to its data transaction.
+func buildManifest(config ManifestConfig, ardriveFiles ArdriveFiles, parentPath string) (arweaveManifest, error) {
+	manifest := arweaveManifest{
+		Manifest: manifestType,
+		Version:  manifestVersion,
+		Paths:    map[string]arweaveManifestPath{},
+	}
+
+	prefix := strings.TrimSuffix(parentPath, "/") + "/"
+	for _, ardriveFile := range ardriveFiles {
+		relativePath, isBelow := strings.CutPrefix(ardriveFile.Path, prefix)
+		if !isBelow || ardriveFile.DataTxId == "" || relativePath == config.Name || ardriveFile.Mimetype == ManifestContentType {
+			continue
+		}
+
+		manifest.Paths[relativePath] = arweaveManifestPath{Id: ardriveFile.DataTxId}
+	}
+
+	if _, exists := manifest.Paths[config.Index]; exists {
+		manifest.Index = &arweaveManifestIndex{Path: config.Index}
+	}
+
+	if config.Fallback != "" {
+		fallback, exists := manifest.Paths[config.Fallback]
+		if !exists {
+			return manifest, fmt.Errorf("fallback %q not found in folder %q", config.Fallback, parentPath)
+		}
+		manifest.Fallback = &fallback
+	}
+
+	return manifest, nil
+}
+
+// writeManifest stages the manifest in its own folder so it can be uploaded under its configured name.
+func writeManifest(manifest arweaveManifest, tmpDirectory, name string) (LocalFile, error) {
+	contents, err := json.Marshal(manifest)
+	if err != nil {
+		return LocalFile{}, fmt.Errorf("unable to encode manifest: %w", err)
+	}
+
+	dir, err := os.MkdirTemp(tmpDirectory, "manifest-*")
+	if err != nil {
+		return LocalFile{}, fmt.Errorf("unable to create manifest folder: %w", err)
+	}
+
+	manifestPath := filepath.Join(dir, name)
+	err = os.WriteFile(manifestPath, contents, 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		return LocalFile{}, fmt.Errorf("unable to write manifest: %w", err)
+	}
+
+	return LocalFile{
+		Dir:      dir,
+		Path:     manifestPath,
+		Mimetype: ManifestContentType,
+	}, nil
+}
@@ -0,0 +1,50 @@
+package sync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManifestListsPendingUploads(t *testing.T) {
+	run := &pipelineRun{
+		parentPath:  "/drive",
+		maxFileSize: 100,
+		onOversize:  OversizeSplit,
+	}
+
+	uploadedAt := time.Now()
+	run.addPending(ObjectStorageFile{Key: "docs/index.html", Size: 10}, LocalFile{Mimetype: "text/html"}, TxData{Created: []File{
+		{Type: arfsEntityFolder, EntityName: "docs", EntityId: "docs-folder"},
+		{Type: arfsEntityFile, EntityName: "index.html", EntityId: "index-entity", DataTxId: "index-tx"},
+	}}, uploadedAt)
+	run.addPending(ObjectStorageFile{Key: "backup.tar", Size: 150}, LocalFile{Mimetype: "application/octet-stream"}, TxData{Created: []File{
+		{Type: arfsEntityFolder, EntityName: "backup.tar.parts", EntityId: "parts-folder"},
+		{Type: arfsEntityFile, EntityName: "backup.tar.part0001", EntityId: "part1-entity", DataTxId: "part1-tx"},
+		{Type: arfsEntityFile, EntityName: "backup.tar.part0002", EntityId: "part2-entity", DataTxId: "part2-tx"},
+	}}, uploadedAt)
+
+	// the gateway has indexed an older revision of index.html only
+	listed := ArdriveFiles{
+		{Path: "/drive/docs/index.html", EntityType: arfsEntityFile, EntityId: "index-entity", DataTxId: "old-tx"},
+		{Path: "/drive/about.html", EntityType: arfsEntityFile, EntityId: "about-entity", DataTxId: "about-tx"},
+	}
+
+	manifest, err := buildManifest(ManifestConfig{Name: DefaultManifestName, Index: DefaultManifestIndex}, run.withPending(listed), run.parentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for relativePath, txId := range map[string]string{
+		"docs/index.html":                      "index-tx",
+		"about.html":                           "about-tx",
+		"backup.tar.parts/backup.tar.part0001": "part1-tx",
+		"backup.tar.parts/backup.tar.part0002": "part2-tx",
+	} {
+		if manifest.Paths[relativePath].Id != txId {
+			t.Errorf("expected %s to point at %s, got %+v", relativePath, txId, manifest.Paths[relativePath])
+		}
+	}
+	if len(manifest.Paths) != 4 {
+		t.Errorf("expected 4 paths, got %v", manifest.Paths)
+	}
+}
@@ -0,0 +1,130 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	gosync "sync"
+	"time"
+)
+
+// MetricsConfig enables an HTTP listener serving metrics in the Prometheus
+// text format under /metrics.
+type MetricsConfig struct {
+	Address string `yaml:"address"`
+}
+
+// Metrics holds the gauges reported by the pipelines.
+type Metrics struct {
+	mutex  gosync.Mutex
+	help   map[string]string
+	gauges map[string]map[string]gaugeValue
+}
+
+type gaugeValue struct {
+	labels map[string]string
+	value  float64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		help:   map[string]string{},
+		gauges: map[string]map[string]gaugeValue{},
+	}
+}
+
+// SetGauge sets the value of the gauge with the given labels.
+func (metrics *Metrics) SetGauge(name, help string, labels map[string]string, value float64) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	metrics.help[name] = help
+	if metrics.gauges[name] == nil {
+		metrics.gauges[name] = map[string]gaugeValue{}
+	}
+	metrics.gauges[name][formatLabels(labels)] = gaugeValue{labels: labels, value: value}
+}
+
+// DeleteGauges removes every value of every gauge carrying the label.
+func (metrics *Metrics) DeleteGauges(label, value string) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	for _, values := range metrics.gauges {
+		for key, gauge := range values {
+			if gauge.labels[label] == value {
+				delete(values, key)
+			}
+		}
+	}
+}
+
+func (metrics *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	names := make([]string, 0, len(metrics.gauges))
+	for name := range metrics.gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, name := range names {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, metrics.help[name], name)
+
+		labels := make([]string, 0, len(metrics.gauges[name]))
+		for label := range metrics.gauges[name] {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+
+		for _, label := range labels {
+			fmt.Fprintf(w, "%s%s %g\n", name, label, metrics.gauges[name][label].value)
+		}
+	}
+}
+
+// serveMetrics serves the metrics until ctx is done.
+func (s *Synchronizer) serveMetrics(ctx context.Context, mux *http.ServeMux) {
+	server := &http.Server{
+		Addr:              s.config.Metrics.Address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	go func() {
+		s.logger.Info("serving metrics", "address", server.Addr)
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("unable to serve metrics", "error", err)
+		}
+	}()
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, labels[name]))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// genericMimetypes are content types that say nothing about the content and
+// are replaced by a detected type.
+var genericMimetypes = map[string]bool{
+	"":                         true,
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+	"application/binary":       true,
+	"application/unknown":      true,
+}
+
+// webMimetypes covers extensions commonly served through manifests that are
+// missing or wrong in the mime tables of minimal container images.
+var webMimetypes = map[string]string{
+	".html":        "text/html; charset=utf-8",
+	".htm":         "text/html; charset=utf-8",
+	".css":         "text/css; charset=utf-8",
+	".js":          "text/javascript; charset=utf-8",
+	".mjs":         "text/javascript; charset=utf-8",
+	".json":        "application/json",
+	".map":         "application/json",
+	".webmanifest": "application/manifest+json",
+	".wasm":        "application/wasm",
+	".svg":         "image/svg+xml",
+	".ico":         "image/x-icon",
+	".webp":        "image/webp",
+	".avif":        "image/avif",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+	".ttf":         "font/ttf",
+	".otf":         "font/otf",
+	".txt":         "text/plain; charset=utf-8",
+	".md":          "text/markdown; charset=utf-8",
+	".xml":         "application/xml",
+	".pdf":         "application/pdf",
+	".mp4":         "video/mp4",
+	".webm":        "video/webm",
+	".mp3":         "audio/mpeg",
+}
+
+type MimetypeDetector struct {
+	overrides []mimetypeOverride
+}
+
+type mimetypeOverride struct {
+	matcher  keyMatcher
+	mimetype string
+}
+
+// NewMimetypeDetector takes overrides keyed by extension (".js") or by the
+// same key patterns used by filters.
+func NewMimetypeDetector(overrides map[string]string) (*MimetypeDetector, error) {
+	patterns := []string{}
+	for pattern := range overrides {
+		patterns = append(patterns, pattern)
+	}
+
+	// the longest, most specific pattern wins when several match
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	detector := &MimetypeDetector{}
+	for _, pattern := range patterns {
+		glob := pattern
+		if strings.HasPrefix(pattern, ".") && !strings.ContainsAny(pattern, "/*?[") {
+			glob = "*" + pattern
+		}
+
+		matchers, err := compileKeyMatchers([]string{glob})
+		if err != nil {
+			return nil, fmt.Errorf("invalid content type override: %w", err)
+		}
+
+		detector.overrides = append(detector.overrides, mimetypeOverride{
+			matcher:  matchers[0],
+			mimetype: overrides[pattern],
+		})
+	}
+
+	return detector, nil
+}
+
+// Detect picks the content type of an object: configured overrides first,
+// then the type reported by object storage unless it is generic, then the
+// file extension and finally sniffing the content of the staged file.
+func (detector *MimetypeDetector) Detect(key, reported, localPath string) (string, error) {
+	if mimetype := detector.DetectWithoutContent(key, reported); mimetype != "" {
+		return mimetype, nil
+	}
+
+	return sniffMimetype(localPath)
+}
+
+// DetectWithoutContent is Detect for objects that are not staged yet. It
+// returns an empty type where only sniffing the content would tell.
+func (detector *MimetypeDetector) DetectWithoutContent(key, reported string) string {
+	for _, override := range detector.overrides {
+		if override.matcher.match(key) {
+			return override.mimetype
+		}
+	}
+
+	if !genericMimetypes[strings.ToLower(reported)] {
+		return reported
+	}
+
+	ext := strings.ToLower(path.Ext(key))
+	if mimetype, exists := webMimetypes[ext]; exists {
+		return mimetype
+	}
+
+	return mime.TypeByExtension(ext)
+}
+
+func sniffMimetype(localPath string) (string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to open %q: %w", localPath, err)
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("unable to read %q: %w", localPath, err)
+	}
+
+	return http.DetectContentType(head[:n]), nil
+}
@@ -0,0 +1,64 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMimetypeDetect(t *testing.T) {
+	detector, err := NewMimetypeDetector(map[string]string{
+		".js":            "application/x-custom",
+		"legacy/**/*.js": "application/javascript",
+		".DAT":           "application/x-dat",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	staged := func(contents string) string {
+		t.Helper()
+		localPath := filepath.Join(dir, "staged")
+		err := os.WriteFile(localPath, []byte(contents), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return localPath
+	}
+
+	for _, test := range []struct {
+		key      string
+		reported string
+		contents string
+		expected string
+	}{
+		{"app.js", "text/plain", "", "application/x-custom"},
+		{"legacy/vendor/app.js", "", "", "application/javascript"},
+		{"data.DAT", "", "", "application/x-dat"},
+		{"photo.jpg", "image/png", "", "image/png"},
+		{"index.html", "binary/octet-stream", "", "text/html; charset=utf-8"},
+		{"STYLE.CSS", "Application/Octet-Stream", "", "text/css; charset=utf-8"},
+		{"feed.xml", "", "", "application/xml"},
+		{"blob", "application/octet-stream", "%PDF-1.7", "application/pdf"},
+		{"blob", "", "", "text/plain; charset=utf-8"},
+	} {
+		mimetype, err := detector.Detect(test.key, test.reported, staged(test.contents))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mimetype != test.expected {
+			t.Errorf("expected %s reported as %q to be %q, got %q", test.key, test.reported, test.expected, mimetype)
+		}
+	}
+
+	if mimetype := detector.DetectWithoutContent("blob", ""); mimetype != "" {
+		t.Errorf("expected objects only sniffing tells to have no type yet, got %q", mimetype)
+	}
+	if _, err := detector.Detect("blob", "", filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected sniffing a missing file to fail")
+	}
+	if _, err := NewMimetypeDetector(map[string]string{"re:[": "text/plain"}); err == nil {
+		t.Error("expected an invalid override pattern to be rejected")
+	}
+}
@@ -0,0 +1,234 @@
+package sync
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const regexFilterPrefix = "re:"
+
+// ObjectFilters narrows the objects a pipeline archives. Patterns are globs
+// unless prefixed with "re:", in which case they are regular expressions
+// matched against the full key. Globs without a "/" match the base name of a
+// key, "**" matches across directories.
+//
+// MinAge skips objects modified more recently than the given duration and
+// RequireStable only syncs objects whose size and ETag did not change between
+// two consecutive listings, so objects still being written are left alone.
+type ObjectFilters struct {
+	Include           []string `yaml:"include"`
+	Exclude           []string `yaml:"exclude"`
+	MinSize           ByteSize `yaml:"min_size"`
+	MaxSize           ByteSize `yaml:"max_size"`
+	Mimetypes         []string `yaml:"mimetypes"`
+	ExcludeMimetypes  []string `yaml:"exclude_mimetypes"`
+	MinAge            Duration `yaml:"min_age"`
+	RequireStable     bool     `yaml:"require_stable"`
+	StabilityInterval Duration `yaml:"stability_interval"`
+}
+
+type ObjectFilter struct {
+	include          []keyMatcher
+	exclude          []keyMatcher
+	minSize          int64
+	maxSize          int64
+	mimetypes        []string
+	excludeMimetypes []string
+	minAge           time.Duration
+	detector         *MimetypeDetector
+}
+
+type keyMatcher struct {
+	pattern  string
+	regex    *regexp.Regexp
+	baseName bool
+}
+
+// NewObjectFilter compiles filters. Mimetype filters are evaluated against
+// the content types detector assigns on upload.
+func NewObjectFilter(filters ObjectFilters, detector *MimetypeDetector) (*ObjectFilter, error) {
+	include, err := compileKeyMatchers(filters.Include)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include filter: %w", err)
+	}
+
+	exclude, err := compileKeyMatchers(filters.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude filter: %w", err)
+	}
+
+	if filters.MaxSize > 0 && filters.MinSize > filters.MaxSize {
+		return nil, fmt.Errorf("min_size %d is larger than max_size %d", filters.MinSize, filters.MaxSize)
+	}
+
+	return &ObjectFilter{
+		include:          include,
+		exclude:          exclude,
+		minSize:          int64(filters.MinSize),
+		maxSize:          int64(filters.MaxSize),
+		mimetypes:        filters.Mimetypes,
+		excludeMimetypes: filters.ExcludeMimetypes,
+		minAge:           time.Duration(filters.MinAge),
+		detector:         detector,
+	}, nil
+}
+
+// Match reports whether the object should be synced and if not, why.
+func (filter *ObjectFilter) Match(objectStorageFile ObjectStorageFile) (bool, string) {
+	key := objectStorageFile.Key
+
+	if len(filter.include) > 0 && !matchesAnyKey(filter.include, key) {
+		return false, "not matched by include filters"
+	}
+
+	for _, matcher := range filter.exclude {
+		if matcher.match(key) {
+			return false, fmt.Sprintf("matched exclude filter %q", matcher.pattern)
+		}
+	}
+
+	if filter.minSize > 0 && objectStorageFile.Size < filter.minSize {
+		return false, fmt.Sprintf("smaller than min_size %d", filter.minSize)
+	}
+
+	if filter.maxSize > 0 && objectStorageFile.Size > filter.maxSize {
+		return false, fmt.Sprintf("larger than max_size %d", filter.maxSize)
+	}
+
+	if filter.minAge > 0 && time.Since(objectStorageFile.LastModified) < filter.minAge {
+		return false, fmt.Sprintf("modified less than min_age %s ago", filter.minAge)
+	}
+
+	if len(filter.mimetypes) == 0 && len(filter.excludeMimetypes) == 0 {
+		return true, ""
+	}
+
+	mimetype := filter.detector.DetectWithoutContent(key, objectStorageFile.Mimetype)
+
+	if len(filter.mimetypes) > 0 && !matchesAnyMimetype(filter.mimetypes, mimetype) {
+		return false, fmt.Sprintf("mimetype %q not matched by mimetype filters", mimetype)
+	}
+
+	if matchesAnyMimetype(filter.excludeMimetypes, mimetype) {
+		return false, fmt.Sprintf("mimetype %q matched exclude_mimetypes", mimetype)
+	}
+
+	return true, ""
+}
+
+func compileKeyMatchers(patterns []string) ([]keyMatcher, error) {
+	matchers := []keyMatcher{}
+	for _, pattern := range patterns {
+		expr := ""
+		baseName := false
+		if strings.HasPrefix(pattern, regexFilterPrefix) {
+			expr = strings.TrimPrefix(pattern, regexFilterPrefix)
+		} else {
+			expr = globToRegex(pattern)
+			baseName = !strings.Contains(pattern, "/")
+		}
+
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to compile %q: %w", pattern, err)
+		}
+
+		matchers = append(matchers, keyMatcher{
+			pattern:  pattern,
+			regex:    regex,
+			baseName: baseName,
+		})
+	}
+
+	return matchers, nil
+}
+
+func (matcher keyMatcher) match(key string) bool {
+	if matcher.baseName {
+		return matcher.regex.MatchString(path.Base(key))
+	}
+
+	return matcher.regex.MatchString(strings.TrimPrefix(key, "/"))
+}
+
+func matchesAnyKey(matchers []keyMatcher, key string) bool {
+	for _, matcher := range matchers {
+		if matcher.match(key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (filter *ObjectFilter) usesMimetypes() bool {
+	return len(filter.mimetypes) > 0 || len(filter.excludeMimetypes) > 0
+}
+
+// matchesAnyMimetype matches exact mimetypes and wildcards such as "image/*".
+func matchesAnyMimetype(patterns []string, mimetype string) bool {
+	mediatype, _, err := mime.ParseMediaType(mimetype)
+	if err != nil {
+		mediatype = mimetype
+	}
+
+	for _, pattern := range patterns {
+		if prefix, isWildcard := strings.CutSuffix(pattern, "/*"); isWildcard {
+			if strings.HasPrefix(mediatype, prefix+"/") {
+				return true
+			}
+		} else if strings.EqualFold(pattern, mediatype) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func globToRegex(glob string) string {
+	glob = strings.TrimPrefix(glob, "/")
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// "**/" also matches no directory at all
+					i++
+					expr.WriteString("(?:.*/)?")
+				} else {
+					expr.WriteString(".*")
+				}
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				expr.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+
+	return expr.String()
+}
@@ -0,0 +1,32 @@
+package sync
+
+import "testing"
+
+func TestObjectFilterMimetypes(t *testing.T) {
+	mimetypes, err := NewMimetypeDetector(map[string]string{".dat": "image/png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := NewObjectFilter(ObjectFilters{ExcludeMimetypes: []string{"image/*"}}, mimetypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, object := range []struct {
+		key      string
+		reported string
+		matched  bool
+	}{
+		{"photo.png", "application/octet-stream", false},
+		{"photo.webp", "", false},
+		{"scan.dat", "application/pdf", false},
+		{"notes.txt", "image/jpeg", false},
+		{"index.html", "binary/octet-stream", true},
+		{"blob", "application/octet-stream", true},
+	} {
+		matched, reason := filter.Match(ObjectStorageFile{Key: object.key, Mimetype: object.reported})
+		if matched != object.matched {
+			t.Errorf("expected %s reported as %q to match %v, got %v: %s", object.key, object.reported, object.matched, matched, reason)
+		}
+	}
+}
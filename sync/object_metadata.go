@@ -0,0 +1,163 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	MetadataTargetJson            = "metadata_json"
+	MetadataTargetMetadataGqlTags = "metadata_gql_tags"
+	MetadataTargetDataGqlTags     = "data_gql_tags"
+
+	metadataSourceUserPrefix = "meta:"
+	metadataSourceTagPrefix  = "tag:"
+	metadataSourceETag       = "etag"
+	metadataSourceVersionId  = "version_id"
+	metadataSourceKey        = "key"
+)
+
+// MetadataMapping maps S3 object metadata onto ArFS custom metadata.
+//
+// Sources are named "meta:<name>" for x-amz-meta-* user metadata,
+// "tag:<name>" for object tags and "etag", "version_id" and "key" for the
+// object itself. Allow limits the sources that are mapped (all when empty),
+// Rename changes the name a source is written under and Static adds fixed
+// values to every file.
+type MetadataMapping struct {
+	Target     string            `yaml:"target"`
+	Allow      []string          `yaml:"allow"`
+	Rename     map[string]string `yaml:"rename"`
+	Static     map[string]string `yaml:"static"`
+	ObjectTags bool              `yaml:"object_tags"`
+}
+
+// ObjectMetadata holds the metadata of an object as reported by object storage.
+type ObjectMetadata struct {
+	UserMetadata map[string]string
+	Tags         map[string]string
+	ETag         string
+	VersionId    string
+}
+
+// ArfsCustomMetadata is attached to an upload as ArFS custom metadata.
+type ArfsCustomMetadata struct {
+	MetadataJson    map[string]string
+	MetadataGqlTags map[string]string
+	DataGqlTags     map[string]string
+}
+
+func (metadata *ArfsCustomMetadata) add(target, name, value string) {
+	var values *map[string]string
+	switch target {
+	case MetadataTargetMetadataGqlTags:
+		values = &metadata.MetadataGqlTags
+	case MetadataTargetDataGqlTags:
+		values = &metadata.DataGqlTags
+	default:
+		values = &metadata.MetadataJson
+	}
+
+	if *values == nil {
+		*values = map[string]string{}
+	}
+	(*values)[name] = value
+}
+
+func validateMetadataMapping(mapping *MetadataMapping) error {
+	if mapping == nil {
+		return nil
+	}
+
+	switch mapping.Target {
+	case "", MetadataTargetJson, MetadataTargetMetadataGqlTags, MetadataTargetDataGqlTags:
+	default:
+		return fmt.Errorf("%q is not a valid metadata target", mapping.Target)
+	}
+
+	for _, source := range mapping.Allow {
+		if !isMetadataSource(source) {
+			return fmt.Errorf("%q is not a valid metadata source", source)
+		}
+	}
+
+	for source := range mapping.Rename {
+		if !isMetadataSource(source) {
+			return fmt.Errorf("%q is not a valid metadata source", source)
+		}
+	}
+
+	return nil
+}
+
+func isMetadataSource(source string) bool {
+	switch source {
+	case metadataSourceETag, metadataSourceVersionId, metadataSourceKey:
+		return true
+	}
+
+	return strings.HasPrefix(source, metadataSourceUserPrefix) || strings.HasPrefix(source, metadataSourceTagPrefix)
+}
+
+// mapObjectMetadata applies the mapping to the metadata of an object.
+func mapObjectMetadata(mapping *MetadataMapping, key string, objectMetadata ObjectMetadata) ArfsCustomMetadata {
+	customMetadata := ArfsCustomMetadata{}
+	if mapping == nil {
+		return customMetadata
+	}
+
+	sources := map[string]string{
+		metadataSourceKey: key,
+	}
+	if objectMetadata.ETag != "" {
+		sources[metadataSourceETag] = objectMetadata.ETag
+	}
+	if objectMetadata.VersionId != "" {
+		sources[metadataSourceVersionId] = objectMetadata.VersionId
+	}
+	for name, value := range objectMetadata.UserMetadata {
+		sources[metadataSourceUserPrefix+strings.ToLower(name)] = value
+	}
+	for name, value := range objectMetadata.Tags {
+		sources[metadataSourceTagPrefix+name] = value
+	}
+
+	allowed := map[string]bool{}
+	for _, source := range mapping.Allow {
+		allowed[strings.ToLower(source)] = true
+	}
+
+	for source, value := range sources {
+		if len(allowed) > 0 && !allowed[strings.ToLower(source)] {
+			continue
+		}
+
+		customMetadata.add(mapping.Target, metadataName(mapping, source), value)
+	}
+
+	for name, value := range mapping.Static {
+		customMetadata.add(mapping.Target, name, value)
+	}
+
+	return customMetadata
+}
+
+func metadataName(mapping *MetadataMapping, source string) string {
+	for from, to := range mapping.Rename {
+		if strings.EqualFold(from, source) {
+			return to
+		}
+	}
+
+	switch source {
+	case metadataSourceETag:
+		return "S3-ETag"
+	case metadataSourceVersionId:
+		return "S3-Version-Id"
+	case metadataSourceKey:
+		return "S3-Key"
+	}
+
+	_, name, _ := strings.Cut(source, ":")
+	return name
+}
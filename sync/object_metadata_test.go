@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMapObjectMetadata(t *testing.T) {
+	objectMetadata := ObjectMetadata{
+		UserMetadata: map[string]string{"Author": "ada"},
+		Tags:         map[string]string{"Env": "prod"},
+		ETag:         "etag",
+		VersionId:    "v1",
+	}
+
+	for _, test := range []struct {
+		name     string
+		mapping  *MetadataMapping
+		metadata ObjectMetadata
+		expected ArfsCustomMetadata
+	}{
+		{
+			name:     "no mapping",
+			metadata: objectMetadata,
+			expected: ArfsCustomMetadata{},
+		},
+		{
+			name:     "all sources",
+			mapping:  &MetadataMapping{},
+			metadata: objectMetadata,
+			expected: ArfsCustomMetadata{MetadataJson: map[string]string{
+				"S3-Key": "docs/a.txt", "S3-ETag": "etag", "S3-Version-Id": "v1", "author": "ada", "Env": "prod",
+			}},
+		},
+		{
+			name:     "object without etag and version",
+			mapping:  &MetadataMapping{Target: MetadataTargetJson},
+			expected: ArfsCustomMetadata{MetadataJson: map[string]string{"S3-Key": "docs/a.txt"}},
+		},
+		{
+			name:     "allowed sources in any case",
+			mapping:  &MetadataMapping{Target: MetadataTargetMetadataGqlTags, Allow: []string{"META:author", "tag:env"}},
+			metadata: objectMetadata,
+			expected: ArfsCustomMetadata{MetadataGqlTags: map[string]string{"author": "ada", "Env": "prod"}},
+		},
+		{
+			name: "renamed and static",
+			mapping: &MetadataMapping{
+				Target: MetadataTargetDataGqlTags,
+				Allow:  []string{"etag", "meta:author"},
+				Rename: map[string]string{"Meta:Author": "Creator"},
+				Static: map[string]string{"Source": "cornelius"},
+			},
+			metadata: objectMetadata,
+			expected: ArfsCustomMetadata{DataGqlTags: map[string]string{"S3-ETag": "etag", "Creator": "ada", "Source": "cornelius"}},
+		},
+	} {
+		customMetadata := mapObjectMetadata(test.mapping, "docs/a.txt", test.metadata)
+		if !reflect.DeepEqual(customMetadata, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, customMetadata)
+		}
+	}
+}
+
+func TestValidateMetadataMapping(t *testing.T) {
+	for _, test := range []struct {
+		mapping *MetadataMapping
+		err     string
+	}{
+		{mapping: nil},
+		{mapping: &MetadataMapping{Target: MetadataTargetDataGqlTags, Allow: []string{"key", "tag:env"}, Rename: map[string]string{"version_id": "Version"}}},
+		{mapping: &MetadataMapping{Target: "headers"}, err: "is not a valid metadata target"},
+		{mapping: &MetadataMapping{Allow: []string{"author"}}, err: `"author" is not a valid metadata source`},
+		{mapping: &MetadataMapping{Rename: map[string]string{"size": "Size"}}, err: `"size" is not a valid metadata source`},
+	} {
+		err := validateMetadataMapping(test.mapping)
+		if test.err == "" && err != nil {
+			t.Errorf("expected %+v to be valid, got %v", test.mapping, err)
+		} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("expected %+v to fail with %q, got %v", test.mapping, test.err, err)
+		}
+	}
+}
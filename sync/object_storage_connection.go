@@ -1,39 +1,94 @@
 package sync
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"math/rand"
 	"path"
 	"path/filepath"
+	"strings"
+	gosync "sync"
 	"time"
 
 	"github.com/the-singularity-labs/cornelius/log"
 
 	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/tags"
 )
 
+const DefaultStabilityInterval = 30 * time.Second
+
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 type ObjectStorageConnection struct {
-	ctx          context.Context
-	minioClient  *minio.Client
-	bucket       string
-	prefix       string
-	isRecursive  bool
-	logger       log.Logger
-	tmpDirectory string
+	ctx               context.Context
+	minioClient       *minio.Client
+	bucket            string
+	prefix            string
+	isRecursive       bool
+	filter            *ObjectFilter
+	requireStable     bool
+	stabilityInterval time.Duration
+	previousListing   map[string]ObjectStorageFile
+	excludedPrefixes  []string
+	excludedSuffixes  []string
+	contentTypes      map[string]cachedContentType
+	contentTypesMutex gosync.Mutex
+	firstSeen         map[string]firstSeen
+	logger            log.Logger
+	tmpDirectory      string
+}
+
+// cachedContentType is the content type of an object at the given ETag.
+type cachedContentType struct {
+	etag        string
+	contentType string
+}
+
+// firstSeen is when an object without a last modified time was first
+// listed at the given ETag.
+type firstSeen struct {
+	etag string
+	at   time.Time
 }
 
 func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
-func NewObjectStorageConnection(ctx context.Context, logger log.Logger, tmpDirectory, host, bucket, prefix, accessId, secretKey string, isSecure, isRecursive bool) (*ObjectStorageConnection, error) {
-	minioClient, err := minio.New(host, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessId, secretKey, ""), // TODO: add support for temp creds
-		Secure: isSecure,
+func NewObjectStorageConnection(ctx context.Context, logger log.Logger, tmpDirectory string, bucket Bucket, accessId, secretKey string, filters ObjectFilters, mimetypes *MimetypeDetector) (*ObjectStorageConnection, error) {
+	filter, err := NewObjectFilter(filters, mimetypes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filters: %w", err)
+	}
+
+	stabilityInterval := time.Duration(filters.StabilityInterval)
+	if stabilityInterval == 0 {
+		stabilityInterval = DefaultStabilityInterval
+	}
+
+	transport, err := newBucketTransport(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize object storage transport: %w", err)
+	}
+
+	lookup, err := bucketLookup(bucket.Addressing)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := newBucketCredentials(bucket, accessId, secretKey, transport)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize object storage credentials: %w", err)
+	}
+
+	minioClient, err := minio.New(bucket.Host, &minio.Options{
+		Creds:        creds,
+		Secure:       bucket.IsSecure,
+		Region:       bucket.Region,
+		BucketLookup: lookup,
+		Transport:    transport,
 	})
 
 	if err != nil {
@@ -41,22 +96,115 @@ func NewObjectStorageConnection(ctx context.Context, logger log.Logger, tmpDirec
 	}
 
 	return &ObjectStorageConnection{
-		minioClient:  minioClient,
-		bucket:       bucket,
-		prefix:       prefix,
-		isRecursive:  isRecursive,
-		logger:       logger,
-		tmpDirectory: path.Join(tmpDirectory, randCharSeq(5)),
+		ctx:               ctx,
+		minioClient:       minioClient,
+		bucket:            bucket.Name,
+		prefix:            bucket.Prefix,
+		isRecursive:       bucket.IsRecursive,
+		filter:            filter,
+		requireStable:     filters.RequireStable,
+		stabilityInterval: stabilityInterval,
+		contentTypes:      map[string]cachedContentType{},
+		firstSeen:         map[string]firstSeen{},
+		logger:            logger,
+		tmpDirectory:      path.Join(tmpDirectory, randCharSeq(5)),
 	}, nil
 }
 
-func (conn *ObjectStorageConnection) ListFiles() (ObjectStorageFiles, error) {
+// ListFiles lists the objects to sync along with the objects skipped by
+// filters. When stability is required, only objects whose size and ETag match
+// the previous listing are returned. The very first call lists twice,
+// stability_interval apart.
+func (conn *ObjectStorageConnection) ListFiles() (ObjectStorageFiles, SkippedObjects, error) {
+	listing, skipped, err := conn.listStableFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := ObjectStorageFiles{}
+	for _, objectStorageFile := range listing {
+		if conn.isExcluded(objectStorageFile.Key) {
+			continue
+		}
+		if objectStorageFile.Mimetype == "" && conn.filter.usesMimetypes() {
+			objectStorageFile.Mimetype, err = conn.ContentType(objectStorageFile)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		if matched, reason := conn.filter.Match(objectStorageFile); !matched {
+			conn.logger.Debug("skipping file, excluded by filters", "key", objectStorageFile.Key, "reason", reason)
+			skipped = append(skipped, SkippedObject{Key: objectStorageFile.Key, Reason: reason})
+			continue
+		}
+		results = append(results, objectStorageFile)
+	}
+
+	// forget the content types of objects that no longer exist
+	listed := listing.byKey()
+	conn.contentTypesMutex.Lock()
+	for key := range conn.contentTypes {
+		if _, exists := listed[key]; !exists {
+			delete(conn.contentTypes, key)
+		}
+	}
+	conn.contentTypesMutex.Unlock()
+
+	return results, skipped, nil
+}
+
+func (conn *ObjectStorageConnection) listStableFiles() (ObjectStorageFiles, SkippedObjects, error) {
+	if !conn.requireStable {
+		listing, err := conn.listFiles()
+		return listing, SkippedObjects{}, err
+	}
+
+	if conn.previousListing == nil {
+		previous, err := conn.listFiles()
+		if err != nil {
+			return nil, nil, err
+		}
+		conn.previousListing = previous.byKey()
+
+		conn.logger.Debug("waiting for objects to settle before comparing listings", "duration", conn.stabilityInterval)
+		select {
+		case <-conn.ctx.Done():
+			return ObjectStorageFiles{}, SkippedObjects{}, nil
+		case <-time.After(conn.stabilityInterval):
+		}
+	}
+
+	current, err := conn.listFiles()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stable := ObjectStorageFiles{}
+	skipped := SkippedObjects{}
+	for _, objectStorageFile := range current {
+		previous, exists := conn.previousListing[objectStorageFile.Key]
+		if !exists || previous.Size != objectStorageFile.Size || previous.ETag != objectStorageFile.ETag {
+			conn.logger.Debug("skipping file, object changed since last listing", "key", objectStorageFile.Key)
+			skipped = append(skipped, SkippedObject{Key: objectStorageFile.Key, Reason: "changed since last listing"})
+			continue
+		}
+		stable = append(stable, objectStorageFile)
+	}
+	conn.previousListing = current.byKey()
+
+	return stable, skipped, nil
+}
+
+func (conn *ObjectStorageConnection) listFiles() (ObjectStorageFiles, error) {
+	// only MinIO reports metadata, including the content type, in listings
 	opts := minio.ListObjectsOptions{
-		Recursive: conn.isRecursive,
-		Prefix:    conn.prefix,
+		Recursive:    conn.isRecursive,
+		Prefix:       conn.prefix,
+		WithMetadata: true,
 	}
 
 	results := ObjectStorageFiles{}
+	seen := map[string]firstSeen{}
 	for objectInfo := range conn.minioClient.ListObjects(context.Background(), conn.bucket, opts) {
 
 		if objectInfo.Err != nil {
@@ -64,30 +212,147 @@ func (conn *ObjectStorageConnection) ListFiles() (ObjectStorageFiles, error) {
 		}
 
 		logger := conn.logger.With("key", objectInfo.Key)
-		if objectInfo.Size == 0 {
-			logger.Warn("skipping file, file is empty and is likely just a folder")
-			continue
-		} else if objectInfo.Size > ArdriveCliFileSizeLimit {
-			logger.Warn("skipping file, exceeds 2GB limit")
+		if strings.HasSuffix(objectInfo.Key, "/") {
+			logger.Debug("skipping folder")
 			continue
 		}
 
+		// objects listed without a last modified time are taken as modified
+		// when they were first listed at their current ETag, so min_age is
+		// eventually met and they are not uploaded again every iteration
 		lastModified := objectInfo.LastModified
 		if lastModified.IsZero() {
-			logger.Debug("Detected last modified tme of epoch 0. This is likely a brand new file. Setting LastModified to now()")
-			lastModified = time.Now()
+			first, exists := conn.firstSeen[objectInfo.Key]
+			if !exists || first.etag != objectInfo.ETag {
+				logger.Debug("object has no last modified time, taking it as modified now")
+				first = firstSeen{etag: objectInfo.ETag, at: time.Now()}
+			}
+			seen[objectInfo.Key] = first
+			lastModified = first.at
 		}
 
-		results = append(results, ObjectStorageFile{
+		objectStorageFile := ObjectStorageFile{
 			Key:          objectInfo.Key,
 			LastModified: lastModified,
-			Mimetype:     objectInfo.ContentType,
-		})
+			Mimetype:     listedContentType(objectInfo),
+			Size:         objectInfo.Size,
+			ETag:         objectInfo.ETag,
+		}
+
+		results = append(results, objectStorageFile)
 	}
+	conn.firstSeen = seen
 
 	return results, nil
 }
 
+// listedContentType is the content type of a listed object, empty when the
+// listing does not report it, as with S3.
+func listedContentType(objectInfo minio.ObjectInfo) string {
+	if objectInfo.ContentType != "" {
+		return objectInfo.ContentType
+	}
+
+	for name, value := range objectInfo.UserMetadata {
+		if strings.EqualFold(name, "content-type") {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// ContentType returns the content type stored for the object, fetching it
+// for objects whose listing did not report it. Results are cached per ETag.
+func (conn *ObjectStorageConnection) ContentType(objectStorageFile ObjectStorageFile) (string, error) {
+	if objectStorageFile.Mimetype != "" {
+		return objectStorageFile.Mimetype, nil
+	}
+
+	conn.contentTypesMutex.Lock()
+	cached, exists := conn.contentTypes[objectStorageFile.Key]
+	conn.contentTypesMutex.Unlock()
+	if exists && cached.etag == objectStorageFile.ETag {
+		return cached.contentType, nil
+	}
+
+	objectInfo, err := conn.minioClient.StatObject(conn.ctx, conn.bucket, objectStorageFile.Key, minio.StatObjectOptions{})
+	if err != nil {
+		return "", fmt.Errorf("unable to stat object %q: %w", objectStorageFile.Key, err)
+	}
+
+	conn.contentTypesMutex.Lock()
+	conn.contentTypes[objectStorageFile.Key] = cachedContentType{etag: objectStorageFile.ETag, contentType: objectInfo.ContentType}
+	conn.contentTypesMutex.Unlock()
+
+	return objectInfo.ContentType, nil
+}
+
+// ExcludePrefix keeps objects below prefix, written by Cornelius itself,
+// out of the listing.
+func (conn *ObjectStorageConnection) ExcludePrefix(prefix string) {
+	if prefix != "" {
+		conn.excludedPrefixes = append(conn.excludedPrefixes, prefix)
+	}
+}
+
+// ExcludeSuffix keeps objects ending with suffix, written by Cornelius
+// itself, out of the listing.
+func (conn *ObjectStorageConnection) ExcludeSuffix(suffix string) {
+	conn.excludedSuffixes = append(conn.excludedSuffixes, suffix)
+}
+
+func (conn *ObjectStorageConnection) isExcluded(key string) bool {
+	for _, prefix := range conn.excludedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	for _, suffix := range conn.excludedSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PutObject writes contents to the bucket under key.
+func (conn *ObjectStorageConnection) PutObject(key string, contents []byte, contentType string) error {
+	_, err := conn.minioClient.PutObject(conn.ctx, conn.bucket, key, bytes.NewReader(contents), int64(len(contents)), minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("unable to upload object: %w", err)
+	}
+
+	return nil
+}
+
+// AddObjectTags sets tags on the object, keeping its other tags.
+func (conn *ObjectStorageConnection) AddObjectTags(key string, values map[string]string) error {
+	objectTags, err := conn.minioClient.GetObjectTagging(conn.ctx, conn.bucket, key, minio.GetObjectTaggingOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to get object tags: %w", err)
+	}
+
+	merged := objectTags.ToMap()
+	for name, value := range values {
+		merged[name] = value
+	}
+
+	objectTags, err = tags.NewTags(merged, true)
+	if err != nil {
+		return fmt.Errorf("invalid object tags: %w", err)
+	}
+
+	err = conn.minioClient.PutObjectTagging(conn.ctx, conn.bucket, key, objectTags, minio.PutObjectTaggingOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to set object tags: %w", err)
+	}
+
+	return nil
+}
+
 func (conn *ObjectStorageConnection) DownloadFile(objectStorageFile ObjectStorageFile) (LocalFile, error) {
 	localFilePath := objectStorageFile.Key
 
@@ -103,6 +368,29 @@ func (conn *ObjectStorageConnection) DownloadFile(objectStorageFile ObjectStorag
 	}, nil
 }
 
+func (conn *ObjectStorageConnection) ObjectMetadata(objectStorageFile ObjectStorageFile, withTags bool) (ObjectMetadata, error) {
+	objectInfo, err := conn.minioClient.StatObject(conn.ctx, conn.bucket, objectStorageFile.Key, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectMetadata{}, fmt.Errorf("unable to stat object: %w", err)
+	}
+
+	objectMetadata := ObjectMetadata{
+		UserMetadata: map[string]string(objectInfo.UserMetadata),
+		ETag:         objectInfo.ETag,
+		VersionId:    objectInfo.VersionID,
+	}
+
+	if withTags {
+		objectTags, err := conn.minioClient.GetObjectTagging(conn.ctx, conn.bucket, objectStorageFile.Key, minio.GetObjectTaggingOptions{VersionID: objectInfo.VersionID})
+		if err != nil {
+			return ObjectMetadata{}, fmt.Errorf("unable to get object tags: %w", err)
+		}
+		objectMetadata.Tags = objectTags.ToMap()
+	}
+
+	return objectMetadata, nil
+}
+
 func randCharSeq(n int) string {
 	b := make([]rune, n)
 	for i := range b {
@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// newListingStub lists the objects of etags, keyed by object key, like S3
+// does for storage that reports no last modified times.
+func newListingStub(t *testing.T, etags map[string]string) *ObjectStorageConnection {
+	t.Helper()
+
+	conn := newStubBucket(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("list-type") != "2" {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+
+		contents := ""
+		for key, etag := range etags {
+			contents += fmt.Sprintf("<Contents><Key>%s</Key><ETag>&quot;%s&quot;</ETag><Size>3</Size></Contents>", key, etag)
+		}
+		fmt.Fprintf(w, "<ListBucketResult><Name>bucket</Name><KeyCount>%d</KeyCount><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated>%s</ListBucketResult>", len(etags), contents)
+	})
+
+	mimetypes, err := NewMimetypeDetector(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.filter, err = NewObjectFilter(ObjectFilters{MinAge: Duration(time.Minute)}, mimetypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+func TestListFilesWithoutLastModified(t *testing.T) {
+	etags := map[string]string{"a.txt": "etag-1", "b.txt": "etag-1"}
+	conn := newListingStub(t, etags)
+
+	listing, skipped, err := conn.ListFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listing) != 0 || len(skipped) != 2 {
+		t.Fatalf("expected objects first listed now to be younger than min_age, got %v, %v", listing, skipped)
+	}
+	firstListed := conn.firstSeen["a.txt"].at
+
+	// the objects age from their first listing on, as long as they keep
+	// their ETag
+	for key, first := range conn.firstSeen {
+		conn.firstSeen[key] = firstSeen{etag: first.etag, at: first.at.Add(-2 * time.Minute)}
+	}
+	etags["b.txt"] = "etag-2"
+
+	listing, skipped, err = conn.ListFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listing) != 1 || listing[0].Key != "a.txt" || !listing[0].LastModified.Equal(firstListed.Add(-2*time.Minute)) {
+		t.Errorf("expected a.txt to be old enough to sync, got %+v", listing)
+	}
+	if len(skipped) != 1 || skipped[0].Key != "b.txt" {
+		t.Errorf("expected the changed b.txt to start aging again, got %+v", skipped)
+	}
+
+	delete(etags, "a.txt")
+	_, _, err = conn.ListFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := conn.firstSeen["a.txt"]; exists {
+		t.Error("expected objects that no longer exist to be forgotten")
+	}
+}
@@ -7,7 +7,18 @@ import (
 type ObjectStorageFile struct {
 	Key          string
 	Mimetype     string
+	Size         int64
+	ETag         string
 	LastModified time.Time
 }
 
 type ObjectStorageFiles []ObjectStorageFile
+
+func (files ObjectStorageFiles) byKey() map[string]ObjectStorageFile {
+	byKey := map[string]ObjectStorageFile{}
+	for _, file := range files {
+		byKey[file.Key] = file
+	}
+
+	return byKey
+}
@@ -1,21 +1,41 @@
 package sync
 
 type Pipeline struct {
-	Name             string           `yaml:"name"`
-	Bucket           Bucket           `yaml:"bucket"`
-	DestinationDrive DestinationDrive `yaml:"drive"`
-	EnableManifest   bool             `yaml:"enable_manifest"`
-	Frequency        Duration         `yaml:"frequency"`
+	Name             string              `yaml:"name"`
+	Bucket           Bucket              `yaml:"bucket"`
+	Filters          ObjectFilters       `yaml:"filters"`
+	DestinationDrive DestinationDrive    `yaml:"drive"`
+	MaxFileSize      ByteSize            `yaml:"max_file_size"`
+	OnOversize       string              `yaml:"on_oversize"`
+	Metadata         *MetadataMapping    `yaml:"metadata"`
+	Tags             map[string]string   `yaml:"tags"`
+	IpfsTag          bool                `yaml:"ipfs_tag"`
+	ContentTypes     map[string]string   `yaml:"content_types"`
+	EnableManifest   bool                `yaml:"enable_manifest"`
+	Manifest         *ManifestConfig     `yaml:"manifest"`
+	Arns             *ArnsConfig         `yaml:"arns"`
+	Gateway          *GatewayConfig      `yaml:"gateway"`
+	Upload           UploadConfig        `yaml:"upload"`
+	Confirmation     *ConfirmationConfig `yaml:"confirmation"`
+	Verify           bool                `yaml:"verify"`
+	Retry            RetryConfig         `yaml:"retry"`
+	Receipts         *ReceiptsConfig     `yaml:"receipts"`
+	WriteBack        *WriteBackConfig    `yaml:"write_back"`
+	Frequency        Duration            `yaml:"frequency"`
 }
 
 type Bucket struct {
-	Name        string `yaml:"name"`
-	Host        string `yaml:"host"`
-	Prefix      string `yaml:"prefix"`
-	AccessId    string `yaml:"access_id"`
-	SecretKey   string `yaml:"secret_key"`
-	IsSecure    bool   `yaml:"is_secure"`
-	IsRecursive bool   `yaml:"is_recursive"`
+	Name        string            `yaml:"name"`
+	Host        string            `yaml:"host"`
+	Region      string            `yaml:"region"`
+	Addressing  string            `yaml:"addressing"`
+	TLS         BucketTLS         `yaml:"tls"`
+	Prefix      string            `yaml:"prefix"`
+	AccessId    string            `yaml:"access_id"`
+	SecretKey   string            `yaml:"secret_key"`
+	Credentials BucketCredentials `yaml:"credentials"`
+	IsSecure    bool              `yaml:"is_secure"`
+	IsRecursive bool              `yaml:"is_recursive"`
 }
 
 type DestinationDrive struct {
@@ -24,4 +44,5 @@ type DestinationDrive struct {
 	Password       string `yaml:"password"`
 	ParentFolderId string `yaml:"parent_folder_id"`
 	IsPublic       bool   `yaml:"is_public"`
+	LowBalance     string `yaml:"low_balance"`
 }
@@ -0,0 +1,569 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/the-singularity-labs/cornelius/arweave"
+	"github.com/the-singularity-labs/cornelius/log"
+)
+
+// pipelineRun holds the connections and settings of a started pipeline
+// across its iterations.
+type pipelineRun struct {
+	pipeline      Pipeline
+	logger        log.Logger
+	objConn       *ObjectStorageConnection
+	ardriveClient *ArdriveClient
+	parentPath    string
+	maxFileSize   int64
+	onOversize    string
+	tags          TransactionTags
+	state         *StateStore
+	mimetypes     *MimetypeDetector
+	manifest      ManifestConfig
+	arns          *ArnsClient
+	turbo         *TurboClient
+	bulk          *bulkUploader
+	confirmations *confirmationTracker
+	gateway       *arweave.Gateway
+	metrics       *Metrics
+	walletAddress string
+	lowBalance    *big.Int
+	retry         retryPolicy
+	failures      int
+	pending       ArdriveFiles
+	receipts      []Receipt
+	writeBacks    []Receipt
+	gatewayURL    string
+	arnsTxId      string
+	tmpDirectory  string
+}
+
+func (run *pipelineRun) iterate(ctx context.Context) error {
+	logger := run.logger
+	run.failures = 0
+
+	defer func() {
+		err := run.state.Flush()
+		if err != nil {
+			logger.Warn("unable to save state", "error", err)
+		}
+	}()
+
+	logger.Info("getting existing files")
+	objectStorageFiles, skipped, err := run.objConn.ListFiles()
+	if err != nil {
+		return fmt.Errorf("unable to get files to sync: %w", err)
+	}
+
+	logger.Info("acquired object storage files", "count", len(objectStorageFiles))
+
+	ardriveFiles, err := run.ardriveClient.ListFiles()
+	if err != nil {
+		return fmt.Errorf("unable to get drives to sync: %w", err)
+	}
+	// dropped bundles are settled before adding the pending entities, so
+	// none of their folders or files are taken as uploaded
+	if run.confirmations != nil {
+		err = run.trackConfirmations(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	ardriveFiles = run.withPending(ardriveFiles)
+
+	logger.Info("acquired ardrive files", "count", len(ardriveFiles))
+
+	splitSize := int64(0)
+	if run.onOversize == OversizeSplit {
+		splitSize = run.maxFileSize
+	}
+
+	deltaObjectStorageFiles, err := identifyNetNewFiles(objectStorageFiles, ardriveFiles, run.parentPath, splitSize)
+	if err != nil {
+		return fmt.Errorf("unable to compare object storage files to ardrive files: %w", err)
+	}
+	if run.confirmations != nil {
+		deltaObjectStorageFiles = run.withDropped(deltaObjectStorageFiles, objectStorageFiles)
+	}
+	if run.retry.deadLetter {
+		var deadLettered SkippedObjects
+		deltaObjectStorageFiles, deadLettered = run.withoutDeadLetters(deltaObjectStorageFiles)
+		skipped = append(skipped, deadLettered...)
+	}
+	logger.Info("idenitifed files to sync", "count", len(deltaObjectStorageFiles))
+
+	run.retryWriteBacks()
+
+	defer func() {
+		run.logSkipped(skipped)
+	}()
+
+	defer func() {
+		err := run.writeReceipts()
+		if err != nil {
+			logger.Error("unable to write receipts, retrying next iteration", "error", err)
+		}
+	}()
+
+	uploads := ObjectStorageFiles{}
+	for _, objectStorageFileToSync := range deltaObjectStorageFiles {
+		if !keyWithinParent(run.parentPath, objectStorageFileToSync.Key) {
+			reason := "key leaves the parent folder"
+			logger.Warn("skipping file, "+reason, "object", objectStorageFileToSync.Key)
+			skipped = append(skipped, SkippedObject{Key: objectStorageFileToSync.Key, Reason: reason})
+			continue
+		}
+		if objectStorageFileToSync.Size > run.maxFileSize {
+			reason := fmt.Sprintf("exceeds max_file_size of %d bytes", run.maxFileSize)
+			switch run.onOversize {
+			case OversizeFail:
+				return fmt.Errorf("object %q of %d bytes %s", objectStorageFileToSync.Key, objectStorageFileToSync.Size, reason)
+			case OversizeSkip:
+				logger.Warn("skipping file, "+reason, "object", objectStorageFileToSync.Key, "size", objectStorageFileToSync.Size)
+				skipped = append(skipped, SkippedObject{Key: objectStorageFileToSync.Key, Reason: reason})
+				continue
+			}
+		}
+		uploads = append(uploads, objectStorageFileToSync)
+	}
+
+	affordable, err := run.checkWalletBalance(ctx, uploads)
+	if err != nil {
+		return err
+	} else if !affordable {
+		return nil
+	}
+
+	if run.turbo != nil && len(uploads) > 0 {
+		affordable, err := run.checkTurboCredits(ctx, uploads)
+		if err != nil {
+			return err
+		} else if !affordable {
+			return nil
+		}
+	}
+
+	uploaded := 0
+	if run.bulk != nil {
+		bundled, singles := run.bulk.partition(uploads, run.maxFileSize)
+		uploaded, err = run.syncBulk(ctx, bundled, ardriveFiles)
+		if err != nil {
+			return err
+		}
+		uploads = singles
+	}
+
+	for _, objectStorageFileToSync := range uploads {
+		if ctx.Err() != nil {
+			logger.Info("pipeline stopped, skipping remaining files")
+			break
+		}
+
+		err := run.retry.retry(ctx, logger.With("object", objectStorageFileToSync.Key), func() error {
+			return run.syncObject(objectStorageFileToSync)
+		})
+		if err != nil {
+			stop, err := run.uploadFailed(ObjectStorageFiles{objectStorageFileToSync}, err)
+			if err != nil {
+				return err
+			} else if stop {
+				break
+			}
+			continue
+		}
+		run.failures = 0
+		uploaded++
+	}
+
+	manifestTxId := run.manifestTxId(ardriveFiles)
+	if run.manifest.Enabled && (uploaded > 0 || manifestTxId == "") {
+		manifestTxId, err = run.publishManifest()
+		if err != nil {
+			return err
+		}
+	}
+
+	if run.arns != nil && manifestTxId != "" {
+		err = run.updateArns(ctx, manifestTxId)
+		if err != nil {
+			return err
+		}
+	}
+
+	if run.pipeline.Verify {
+		err = run.verifyUploads(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkTurboCredits reports whether the credit balance covers the files to
+// upload, warning when it is or would drop below the low balance threshold.
+func (run *pipelineRun) checkTurboCredits(ctx context.Context, uploads ObjectStorageFiles) (bool, error) {
+	pendingBytes := int64(0)
+	for _, upload := range uploads {
+		pendingBytes += upload.Size
+	}
+
+	balance, err := run.turbo.Balance(ctx)
+	if err != nil {
+		return false, fmt.Errorf("unable to get turbo credit balance: %w", err)
+	}
+
+	price, err := run.turbo.Price(ctx, pendingBytes)
+	if err != nil {
+		return false, fmt.Errorf("unable to get turbo upload price: %w", err)
+	}
+
+	logger := run.logger.With("balance", formatTokenAmount(balance), "price", formatTokenAmount(price), "bytes", pendingBytes)
+	if balance.Cmp(price) < 0 {
+		logger.Warn("turbo credits do not cover pending uploads, skipping uploads this iteration")
+		return false, nil
+	}
+
+	remaining := new(big.Int).Sub(balance, price)
+	if remaining.Cmp(run.turbo.lowBalance) < 0 {
+		logger.Warn("turbo credit balance is low", "low_balance", formatTokenAmount(run.turbo.lowBalance), "remaining", formatTokenAmount(remaining))
+	} else {
+		logger.Info("turbo credits cover pending uploads")
+	}
+
+	return true, nil
+}
+
+// manifestTxId returns the data transaction of the current manifest, if any.
+func (run *pipelineRun) manifestTxId(ardriveFiles ArdriveFiles) string {
+	manifestPath := filepath.Join(run.parentPath, run.manifest.Name)
+	for _, ardriveFile := range ardriveFiles {
+		if ardriveFile.Path == manifestPath {
+			return ardriveFile.DataTxId
+		}
+	}
+
+	return ""
+}
+
+// publishManifest regenerates the manifest of the parent folder once all
+// files of the iteration have been uploaded and returns its data transaction.
+func (run *pipelineRun) publishManifest() (string, error) {
+	ardriveFiles, err := run.ardriveClient.ListFiles()
+	if err != nil {
+		return "", fmt.Errorf("unable to list ardrive files for manifest: %w", err)
+	}
+	ardriveFiles = run.withPending(ardriveFiles)
+
+	manifest, err := buildManifest(run.manifest, ardriveFiles, run.parentPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to build manifest: %w", err)
+	}
+
+	if manifest.Index == nil {
+		run.logger.Warn("manifest index not found, manifest will have no index", "index", run.manifest.Index)
+	}
+
+	manifestFile, err := writeManifest(manifest, run.tmpDirectory, run.manifest.Name)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(manifestFile.Dir)
+
+	txData, err := run.ardriveClient.upsertManifest(manifestFile)
+	if err != nil {
+		return "", err
+	}
+
+	createdFile, _ := txData.CreatedFile()
+	run.logger.Info("manifest published", "manifest_tx_id", createdFile.DataTxId, "entity_id", createdFile.EntityId, "paths", len(manifest.Paths))
+
+	err = run.state.UpdatePipeline(run.pipeline.Name, func(pipelineState *PipelineState) {
+		pipelineState.Manifest = &ManifestState{
+			EntityId:  createdFile.EntityId,
+			TxId:      createdFile.DataTxId,
+			Paths:     len(manifest.Paths),
+			UpdatedAt: time.Now(),
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to record manifest state: %w", err)
+	}
+
+	return createdFile.DataTxId, nil
+}
+
+// updateArns points the ArNS name at the manifest unless this or a previous
+// run already did.
+func (run *pipelineRun) updateArns(ctx context.Context, manifestTxId string) error {
+	if run.arnsTxId == manifestTxId {
+		return nil
+	}
+
+	arnsState := run.state.Pipeline(run.pipeline.Name).Arns
+	if arnsState != nil && arnsState.ProcessId == run.arns.processId && arnsState.Undername == run.arns.undername && arnsState.TxId == manifestTxId {
+		run.arnsTxId = manifestTxId
+		return nil
+	}
+
+	messageId, err := run.arns.SetRecord(ctx, manifestTxId)
+	if err != nil {
+		return fmt.Errorf("unable to update arns record %q: %w", run.arns.undername, err)
+	}
+	run.arnsTxId = manifestTxId
+
+	run.logger.Info("arns record updated", "process_id", run.arns.processId, "undername", run.arns.undername, "manifest_tx_id", manifestTxId, "message_id", messageId)
+
+	err = run.state.UpdatePipeline(run.pipeline.Name, func(pipelineState *PipelineState) {
+		pipelineState.Arns = &ArnsState{
+			ProcessId: run.arns.processId,
+			Undername: run.arns.undername,
+			TxId:      manifestTxId,
+			MessageId: messageId,
+			UpdatedAt: time.Now(),
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record arns state: %w", err)
+	}
+
+	return nil
+}
+
+func (run *pipelineRun) syncObject(objectStorageFileToSync ObjectStorageFile) error {
+	logger := run.logger.With("object", objectStorageFileToSync.Key)
+	localFile, ipfsCid, err := run.prepareObject(objectStorageFileToSync)
+	defer func() {
+		logger.Debug("removing staged file")
+		removeLocalFile(localFile)
+	}()
+	if err != nil {
+		return err
+	}
+
+	uploadFile := localFile
+	if objectStorageFileToSync.Size > run.maxFileSize && run.onOversize == OversizeSplit {
+		logger.Info("splitting file exceeding max_file_size", "size", objectStorageFileToSync.Size, "max_file_size", run.maxFileSize)
+		uploadFile, err = splitLocalFile(localFile, run.maxFileSize)
+		if err != nil {
+			return fmt.Errorf("unable to split %q: %w", objectStorageFileToSync.Key, err)
+		}
+		uploadFile.CustomMetadata = localFile.CustomMetadata
+	}
+
+	txData, err := run.ardriveClient.upsertFile(uploadFile) // TODO: compile response statistics intometrics
+	if errors.Is(err, errCommandTimedOut) {
+		return run.timedOutUpload(objectStorageFileToSync, localFile, ipfsCid, err)
+	} else if err != nil {
+		return fmt.Errorf("unable to upsert %q to arweave: %w", uploadFile.Dir, err)
+	}
+
+	totalFees, err := txData.TotalFees()
+	if err != nil {
+		return fmt.Errorf("unable to upsert %q to arweave: %w", strings.Join(txData.EntityIds(), ", "), err)
+	}
+
+	logger.Info("file uploaded to arweave", "fees_paid", totalFees)
+
+	createdFile, _ := txData.CreatedFile()
+	uploadedAt := time.Now()
+	receipt := Receipt{
+		Key:          objectStorageFileToSync.Key,
+		Size:         objectStorageFileToSync.Size,
+		Sha256:       localFile.Sha256,
+		EntityId:     createdFile.EntityId,
+		DataTxId:     createdFile.DataTxId,
+		MetadataTxId: createdFile.MetadataTxId,
+		BundledIn:    createdFile.BundledIn,
+		Fees:         strconv.FormatInt(totalFees, 10),
+		UploadedAt:   uploadedAt,
+	}
+	run.addReceipt(receipt)
+	run.writeBack(receipt)
+
+	err = run.state.UpdateFile(run.pipeline.Name, objectStorageFileToSync.Key, func(fileState *FileState) {
+		fileState.Size = objectStorageFileToSync.Size
+		fileState.Sha256 = localFile.Sha256
+		fileState.IpfsCid = ipfsCid
+		fileState.EntityId = createdFile.EntityId
+		fileState.DataTxId = createdFile.DataTxId
+		fileState.MetadataTxId = createdFile.MetadataTxId
+		fileState.BundledIn = createdFile.BundledIn
+		fileState.markUploaded(uploadedAt)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record state of %q: %w", objectStorageFileToSync.Key, err)
+	}
+	run.addPending(objectStorageFileToSync, uploadFile, txData, uploadedAt)
+
+	return nil
+}
+
+// addPending adds the files created by an ardrive-cli upload to the pending
+// entries, so the manifest published at the end of the iteration lists them
+// although the gateway has not indexed them yet, as with files uploaded in
+// bundles.
+func (run *pipelineRun) addPending(objectStorageFile ObjectStorageFile, uploadFile LocalFile, txData TxData, uploadedAt time.Time) {
+	split := run.onOversize == OversizeSplit && objectStorageFile.Size > run.maxFileSize
+	for _, created := range txData.Created {
+		if created.Type != arfsEntityFile {
+			continue
+		}
+
+		drivePath := path.Join(run.parentPath, objectStorageFile.Key)
+		if split {
+			drivePath = path.Join(run.parentPath, splitPartsKey(objectStorageFile.Key), created.EntityName)
+		} else if created.EntityName != path.Base(objectStorageFile.Key) {
+			continue
+		}
+
+		run.pending = append(run.pending, ArdriveFile{
+			Path:         drivePath,
+			EntityType:   arfsEntityFile,
+			EntityId:     created.EntityId,
+			DataTxId:     created.DataTxId,
+			Mimetype:     uploadFile.Mimetype,
+			LastModified: uploadedAt,
+		})
+	}
+}
+
+// timedOutUpload settles an upload whose ardrive-cli command timed out. Its
+// transactions may have been posted and paid for regardless, so the drive is
+// listed again: an upload that shows up is recorded as such, otherwise the
+// object is left for the next iteration rather than retried right away.
+func (run *pipelineRun) timedOutUpload(objectStorageFile ObjectStorageFile, localFile LocalFile, ipfsCid string, err error) error {
+	logger := run.logger.With("object", objectStorageFile.Key)
+	logger.Warn("upload timed out, listing the drive before uploading again", "error", err)
+
+	ardriveFiles, listErr := run.ardriveClient.ListFiles()
+	if listErr != nil {
+		return &ambiguousUploadError{err: fmt.Errorf("%w, unable to list the drive: %v", err, listErr)}
+	}
+
+	splitSize := int64(0)
+	if run.onOversize == OversizeSplit {
+		splitSize = run.maxFileSize
+	}
+	drivePath := ardrivePathForObject(run.parentPath, objectStorageFile, splitSize)
+
+	for _, ardriveFile := range ardriveFiles {
+		if ardriveFile.Path != drivePath || objectStorageFile.LastModified.After(ardriveFile.LastModified) {
+			continue
+		}
+
+		logger.Info("upload completed despite timing out", "entity_id", ardriveFile.EntityId, "data_tx_id", ardriveFile.DataTxId)
+		uploadedAt := time.Now()
+		receipt := Receipt{
+			Key:        objectStorageFile.Key,
+			Size:       objectStorageFile.Size,
+			Sha256:     localFile.Sha256,
+			EntityId:   ardriveFile.EntityId,
+			DataTxId:   ardriveFile.DataTxId,
+			UploadedAt: uploadedAt,
+		}
+		run.addReceipt(receipt)
+		run.writeBack(receipt)
+
+		err = run.state.UpdateFile(run.pipeline.Name, objectStorageFile.Key, func(fileState *FileState) {
+			fileState.Size = objectStorageFile.Size
+			fileState.Sha256 = localFile.Sha256
+			fileState.IpfsCid = ipfsCid
+			fileState.EntityId = ardriveFile.EntityId
+			fileState.DataTxId = ardriveFile.DataTxId
+			fileState.MetadataTxId = ""
+			fileState.BundledIn = ""
+			fileState.markUploaded(uploadedAt)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to record state of %q: %w", objectStorageFile.Key, err)
+		}
+
+		return nil
+	}
+
+	return &ambiguousUploadError{err: err}
+}
+
+// prepareObject downloads the object and fills in the content type, hash and
+// custom metadata it is uploaded with. The returned file needs to be removed
+// by the caller, even on error.
+func (run *pipelineRun) prepareObject(objectStorageFileToSync ObjectStorageFile) (LocalFile, string, error) {
+	logger := run.logger.With("object", objectStorageFileToSync.Key)
+	logger.Debug("downloading file from object storage")
+	localFile, err := run.objConn.DownloadFile(objectStorageFileToSync)
+	if err != nil {
+		return localFile, "", fmt.Errorf("unable to download object %q in order to reupload to arweave: %w", objectStorageFileToSync.Key, err)
+	}
+
+	logger.Debug("finished dowloading file from object storage", "path", localFile.Path)
+
+	reported, err := run.objConn.ContentType(objectStorageFileToSync)
+	if err != nil {
+		return localFile, "", fmt.Errorf("unable to get content type of object %q: %w", objectStorageFileToSync.Key, err)
+	}
+
+	localFile.Mimetype, err = run.mimetypes.Detect(objectStorageFileToSync.Key, reported, localFile.Path)
+	if err != nil {
+		return localFile, "", fmt.Errorf("unable to detect content type of object %q: %w", objectStorageFileToSync.Key, err)
+	}
+
+	localFile.Sha256, err = fileSHA256(localFile.Path)
+	if err != nil {
+		return localFile, "", fmt.Errorf("unable to hash object %q: %w", objectStorageFileToSync.Key, err)
+	}
+
+	if run.pipeline.Metadata != nil {
+		objectMetadata, err := run.objConn.ObjectMetadata(objectStorageFileToSync, run.pipeline.Metadata.ObjectTags)
+		if err != nil {
+			return localFile, "", fmt.Errorf("unable to get metadata of object %q: %w", objectStorageFileToSync.Key, err)
+		}
+		localFile.CustomMetadata = mapObjectMetadata(run.pipeline.Metadata, objectStorageFileToSync.Key, objectMetadata)
+	}
+
+	ipfsCid := ""
+	if run.pipeline.IpfsTag {
+		ipfsCid, err = fileIpfsCid(localFile.Path)
+		if err != nil {
+			return localFile, "", fmt.Errorf("unable to compute ipfs cid of object %q: %w", objectStorageFileToSync.Key, err)
+		}
+		localFile.CustomMetadata.add(MetadataTargetDataGqlTags, IpfsAddTag, ipfsCid)
+	}
+
+	tags, err := run.tags.Render(TransactionTagData{
+		Key:          objectStorageFileToSync.Key,
+		Bucket:       run.pipeline.Bucket.Name,
+		PipelineName: run.pipeline.Name,
+		DriveId:      run.pipeline.DestinationDrive.Id,
+		SHA256:       localFile.Sha256,
+		Size:         objectStorageFileToSync.Size,
+		ContentType:  localFile.Mimetype,
+	})
+	if err != nil {
+		return localFile, "", fmt.Errorf("unable to render tags for object %q: %w", objectStorageFileToSync.Key, err)
+	}
+	for name, value := range tags {
+		localFile.CustomMetadata.add(MetadataTargetDataGqlTags, name, value)
+	}
+
+	return localFile, ipfsCid, nil
+}
+
+func (run *pipelineRun) logSkipped(skipped SkippedObjects) {
+	if len(skipped) == 0 {
+		return
+	}
+
+	run.logger.Info("skipped objects this iteration", "count", len(skipped), "reasons", skipped.Summary())
+	run.logger.Debug("skipped object keys", "keys", skipped.Keys())
+}
@@ -0,0 +1,183 @@
+package sync
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ReceiptFormatJsonl = "jsonl"
+	ReceiptFormatCsv   = "csv"
+)
+
+// ReceiptsConfig configures the receipts written after every iteration that
+// uploaded files, either to a local directory or under a prefix of the
+// source bucket.
+type ReceiptsConfig struct {
+	Format string `yaml:"format"`
+	Path   string `yaml:"path"`
+	Prefix string `yaml:"prefix"`
+}
+
+// Receipt records where an uploaded object lives on Arweave. Fees are in
+// winston; objects uploaded in the same bundle share the fees of the bundle
+// by the size of their data items.
+type Receipt struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	Sha256       string    `json:"sha256"`
+	EntityId     string    `json:"entity_id"`
+	DataTxId     string    `json:"data_tx_id"`
+	MetadataTxId string    `json:"metadata_tx_id"`
+	BundledIn    string    `json:"bundled_in,omitempty"`
+	Fees         string    `json:"fees"`
+	UploadedAt   time.Time `json:"uploaded_at"`
+}
+
+var receiptColumns = []string{"key", "size", "sha256", "entity_id", "data_tx_id", "metadata_tx_id", "bundled_in", "fees", "uploaded_at"}
+
+func (config ReceiptsConfig) validate() error {
+	switch config.Format {
+	case "", ReceiptFormatJsonl, ReceiptFormatCsv:
+	default:
+		return fmt.Errorf("%q is not a valid receipt format, must be %q or %q", config.Format, ReceiptFormatJsonl, ReceiptFormatCsv)
+	}
+
+	if (config.Path == "") == (config.Prefix == "") {
+		return fmt.Errorf("exactly one of path and prefix must be set")
+	}
+
+	return nil
+}
+
+func (config ReceiptsConfig) format() string {
+	if config.Format == "" {
+		return ReceiptFormatJsonl
+	}
+
+	return config.Format
+}
+
+func encodeReceipts(format string, receipts []Receipt) ([]byte, error) {
+	buffer := bytes.Buffer{}
+	if format == ReceiptFormatCsv {
+		writer := csv.NewWriter(&buffer)
+		writer.Write(receiptColumns)
+		for _, receipt := range receipts {
+			writer.Write([]string{
+				receipt.Key,
+				strconv.FormatInt(receipt.Size, 10),
+				receipt.Sha256,
+				receipt.EntityId,
+				receipt.DataTxId,
+				receipt.MetadataTxId,
+				receipt.BundledIn,
+				receipt.Fees,
+				receipt.UploadedAt.Format(time.RFC3339),
+			})
+		}
+		writer.Flush()
+
+		return buffer.Bytes(), writer.Error()
+	}
+
+	encoder := json.NewEncoder(&buffer)
+	for _, receipt := range receipts {
+		err := encoder.Encode(receipt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// addReceipt records an upload for the receipt of the current iteration.
+// Receipts not written yet are kept in the state so they survive restarts.
+func (run *pipelineRun) addReceipt(receipt Receipt) {
+	if run.pipeline.Receipts == nil {
+		return
+	}
+
+	run.receipts = append(run.receipts, receipt)
+	run.saveReceipts()
+}
+
+// restoreReceipts picks up the receipts a previous run did not write.
+func (run *pipelineRun) restoreReceipts() {
+	run.receipts = run.state.Pipeline(run.pipeline.Name).Receipts
+}
+
+func (run *pipelineRun) saveReceipts() {
+	err := run.state.UpdatePipeline(run.pipeline.Name, func(pipelineState *PipelineState) {
+		pipelineState.Receipts = run.receipts
+	})
+	if err != nil {
+		run.logger.Warn("unable to record pending receipts", "error", err)
+	}
+}
+
+// writeReceipts writes the receipts of the iteration. Receipts that could
+// not be written are kept and written along with the next iteration's.
+func (run *pipelineRun) writeReceipts() error {
+	if len(run.receipts) == 0 {
+		return nil
+	}
+
+	config := *run.pipeline.Receipts
+	contents, err := encodeReceipts(config.format(), run.receipts)
+	if err != nil {
+		return fmt.Errorf("unable to encode receipts: %w", err)
+	}
+
+	// the random suffix keeps receipts written within the same second apart
+	name := fmt.Sprintf("%s-%s-%s.%s", run.pipeline.Name, time.Now().UTC().Format("20060102T150405Z"), randCharSeq(5), config.format())
+	if config.Prefix != "" {
+		key := path.Join(config.Prefix, name)
+		contentType := "application/jsonl"
+		if config.format() == ReceiptFormatCsv {
+			contentType = "text/csv"
+		}
+
+		err = run.objConn.PutObject(key, contents, contentType)
+		if err != nil {
+			return fmt.Errorf("unable to write receipt %q: %w", key, err)
+		}
+		run.logger.Info("wrote receipt", "key", key, "files", len(run.receipts))
+	} else {
+		err = os.MkdirAll(config.Path, 0755)
+		if err != nil {
+			return fmt.Errorf("unable to create receipts directory: %w", err)
+		}
+
+		receiptPath := filepath.Join(config.Path, name)
+		err = os.WriteFile(receiptPath, contents, 0644)
+		if err != nil {
+			return fmt.Errorf("unable to write receipt %q: %w", receiptPath, err)
+		}
+		run.logger.Info("wrote receipt", "path", receiptPath, "files", len(run.receipts))
+	}
+
+	run.receipts = nil
+	run.saveReceipts()
+
+	return nil
+}
+
+// receiptsPrefix is the bucket prefix receipts are written to, excluded from
+// the objects to sync.
+func (pipeline Pipeline) receiptsPrefix() string {
+	if pipeline.Receipts == nil || pipeline.Receipts.Prefix == "" {
+		return ""
+	}
+
+	return strings.TrimSuffix(pipeline.Receipts.Prefix, "/") + "/"
+}
@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/the-singularity-labs/cornelius/log"
+)
+
+func TestReceiptsSurviveRestarts(t *testing.T) {
+	dir := t.TempDir()
+	receiptsDir := filepath.Join(dir, "receipts")
+	pipeline := Pipeline{Name: "site", Receipts: &ReceiptsConfig{Path: receiptsDir}}
+
+	newRun := func() *pipelineRun {
+		state, err := NewStateStore(filepath.Join(dir, "state.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		run := &pipelineRun{pipeline: pipeline, logger: log.NewTextLogger(slog.LevelError), state: state}
+		run.restoreReceipts()
+		return run
+	}
+
+	run := newRun()
+	run.addReceipt(Receipt{Key: "index.html", Size: 42, DataTxId: "tx", UploadedAt: time.Now().UTC()})
+
+	// a restart before the receipt was written picks it up again
+	restarted := newRun()
+	if len(restarted.receipts) != 1 || restarted.receipts[0].Key != "index.html" {
+		t.Fatalf("expected the pending receipt to be restored, got %+v", restarted.receipts)
+	}
+
+	err := restarted.writeReceipts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(receiptsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !strings.HasPrefix(entries[0].Name(), "site-") {
+		t.Fatalf("expected one receipt, got %v", entries)
+	}
+	contents, err := os.ReadFile(filepath.Join(receiptsDir, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(contents), `"key":"index.html"`) {
+		t.Errorf("expected the receipt to list index.html, got %s", contents)
+	}
+
+	if receipts := newRun().receipts; len(receipts) != 0 {
+		t.Errorf("expected written receipts to be cleared from the state, got %+v", receipts)
+	}
+}
+
+func TestReceiptsWrittenInTheSameSecond(t *testing.T) {
+	receiptsDir := filepath.Join(t.TempDir(), "receipts")
+	run := &pipelineRun{pipeline: Pipeline{Name: "site", Receipts: &ReceiptsConfig{Path: receiptsDir}}, logger: log.NewTextLogger(slog.LevelError)}
+
+	for _, key := range []string{"a.txt", "b.txt"} {
+		run.receipts = []Receipt{{Key: key, UploadedAt: time.Now().UTC()}}
+		err := run.writeReceipts()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(receiptsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected receipts written back to back to be kept apart, got %v", entries)
+	}
+}
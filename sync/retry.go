@@ -0,0 +1,290 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/the-singularity-labs/cornelius/arweave"
+	"github.com/the-singularity-labs/cornelius/log"
+)
+
+const (
+	ErrorTransient         = "transient"
+	ErrorPermanent         = "permanent"
+	ErrorInsufficientFunds = "insufficient_funds"
+	ErrorAmbiguous         = "ambiguous"
+
+	DefaultRetryMaxAttempts               = 3
+	DefaultRetryInitialBackoff            = Duration(2 * time.Second)
+	DefaultRetryMaxBackoff                = Duration(time.Minute)
+	DefaultRetryFailureThreshold          = 10
+	DefaultRetryIterationFailureThreshold = 10
+)
+
+// RetryConfig controls how often a failing upload is retried, after how
+// many consecutive failed uploads within an iteration or consecutive failed
+// iterations a pipeline gives up and whether objects that still fail are
+// dead-lettered.
+type RetryConfig struct {
+	MaxAttempts               int      `yaml:"max_attempts"`
+	InitialBackoff            Duration `yaml:"initial_backoff"`
+	MaxBackoff                Duration `yaml:"max_backoff"`
+	FailureThreshold          int      `yaml:"failure_threshold"`
+	IterationFailureThreshold int      `yaml:"iteration_failure_threshold"`
+	DeadLetter                bool     `yaml:"dead_letter"`
+}
+
+type retryPolicy struct {
+	maxAttempts               int
+	initialBackoff            time.Duration
+	maxBackoff                time.Duration
+	failureThreshold          int
+	iterationFailureThreshold int
+	deadLetter                bool
+}
+
+// giveUpError is returned once a pipeline reaches a failure threshold. It is
+// permanent whatever the class of the failure it wraps.
+type giveUpError struct {
+	reason string
+	err    error
+}
+
+func (err *giveUpError) Error() string {
+	return fmt.Sprintf("%s: %v", err.reason, err.err)
+}
+
+func (err *giveUpError) Unwrap() error {
+	return err.err
+}
+
+// retryError is returned by retry once an operation failed for good.
+type retryError struct {
+	attempts int
+	err      error
+}
+
+func (err *retryError) Error() string {
+	return fmt.Sprintf("failed after %d attempts: %v", err.attempts, err.err)
+}
+
+func (err *retryError) Unwrap() error {
+	return err.err
+}
+
+// ambiguousUploadError is returned for uploads that timed out without
+// showing up on the drive. They may still have been posted and paid for, so
+// they are not retried before the drive was listed again on the next
+// iteration.
+type ambiguousUploadError struct {
+	err error
+}
+
+func (err *ambiguousUploadError) Error() string {
+	return fmt.Sprintf("upload outcome unknown: %v", err.err)
+}
+
+func (err *ambiguousUploadError) Unwrap() error {
+	return err.err
+}
+
+// insufficientFundsMessages mark errors of wallets that cannot pay for an upload.
+var insufficientFundsMessages = []string{
+	"insufficient funds",
+	"insufficient balance",
+	"not enough funds",
+	"payment required",
+}
+
+// transientMessages mark errors, mostly reported by ardrive-cli, that are
+// worth retrying.
+var transientMessages = []string{
+	"command timed out",
+	"econnreset",
+	"econnrefused",
+	"etimedout",
+	"enotfound",
+	"eai_again",
+	"socket hang up",
+	"timeout",
+	"too many requests",
+	"bad gateway",
+	"service unavailable",
+	"gateway timeout",
+	"connection reset",
+	"connection refused",
+	"status code 429",
+	"status code 500",
+	"status code 502",
+	"status code 503",
+	"status code 504",
+}
+
+func newRetryPolicy(config RetryConfig) (retryPolicy, error) {
+	policy := retryPolicy{
+		maxAttempts:               config.MaxAttempts,
+		initialBackoff:            time.Duration(config.InitialBackoff),
+		maxBackoff:                time.Duration(config.MaxBackoff),
+		failureThreshold:          config.FailureThreshold,
+		iterationFailureThreshold: config.IterationFailureThreshold,
+		deadLetter:                config.DeadLetter,
+	}
+
+	if policy.maxAttempts == 0 {
+		policy.maxAttempts = DefaultRetryMaxAttempts
+	}
+	if policy.initialBackoff == 0 {
+		policy.initialBackoff = time.Duration(DefaultRetryInitialBackoff)
+	}
+	if policy.maxBackoff == 0 {
+		policy.maxBackoff = time.Duration(DefaultRetryMaxBackoff)
+	}
+	if policy.failureThreshold == 0 {
+		policy.failureThreshold = DefaultRetryFailureThreshold
+	}
+	if policy.iterationFailureThreshold == 0 {
+		policy.iterationFailureThreshold = DefaultRetryIterationFailureThreshold
+	}
+
+	if policy.maxAttempts < 0 || policy.failureThreshold < 0 || policy.iterationFailureThreshold < 0 || policy.initialBackoff < 0 || policy.maxBackoff < policy.initialBackoff {
+		return policy, fmt.Errorf("attempts, thresholds and backoffs must be positive and max_backoff at least initial_backoff")
+	}
+
+	return policy, nil
+}
+
+// retry runs fn until it succeeds, fails with an error that is not
+// transient or has been attempted maxAttempts times.
+func (policy retryPolicy) retry(ctx context.Context, logger log.Logger, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		class := classifyError(err)
+		if class != ErrorTransient || attempt >= policy.maxAttempts || ctx.Err() != nil {
+			return &retryError{attempts: attempt, err: err}
+		}
+
+		backoff := policy.backoff(attempt)
+		logger.Warn("transient error, retrying", "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return &retryError{attempts: attempt, err: err}
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// backoff doubles the delay with every attempt up to maxBackoff and picks
+// a random point within its upper half so pipelines do not retry in lockstep.
+func (policy retryPolicy) backoff(attempt int) time.Duration {
+	backoff := policy.initialBackoff
+	for i := 1; i < attempt && backoff < policy.maxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, policy.maxBackoff)
+
+	half := int64(backoff / 2)
+	if half <= 0 {
+		return backoff
+	}
+
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// classifyError tells whether an error is worth retrying, caused by a
+// wallet that cannot pay for the upload, of an upload that may have
+// succeeded or permanent.
+func classifyError(err error) string {
+	var giveUpErr *giveUpError
+	if errors.As(err, &giveUpErr) {
+		return ErrorPermanent
+	}
+
+	var ambiguousErr *ambiguousUploadError
+	if errors.As(err, &ambiguousErr) {
+		return ErrorAmbiguous
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, fundsMessage := range insufficientFundsMessages {
+		if strings.Contains(message, fundsMessage) {
+			return ErrorInsufficientFunds
+		}
+	}
+
+	var statusErr *arweave.StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusPaymentRequired:
+			return ErrorInsufficientFunds
+		case statusErr.StatusCode == http.StatusTooManyRequests, statusErr.StatusCode >= 500:
+			return ErrorTransient
+		}
+		return ErrorPermanent
+	}
+
+	var minioErr minio.ErrorResponse
+	if errors.As(err, &minioErr) {
+		if minioErr.StatusCode == http.StatusTooManyRequests || minioErr.StatusCode >= 500 || minioErr.Code == "SlowDown" {
+			return ErrorTransient
+		}
+		return ErrorPermanent
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorTransient
+	}
+
+	for _, transientMessage := range transientMessages {
+		if strings.Contains(message, transientMessage) {
+			return ErrorTransient
+		}
+	}
+
+	return ErrorPermanent
+}
+
+// uploadFailed handles objects whose upload failed after all retries. It
+// reports whether the remaining uploads of the iteration should be skipped
+// and returns an error once the pipeline should give up. A failed bundle
+// counts as a single failed upload. Failures of single objects, including
+// objects that could not be staged for a bundle, are dead-lettered when
+// enabled, unless the upload may have succeeded. Failed bundle uploads
+// cannot be attributed to one of their objects.
+func (run *pipelineRun) uploadFailed(objects ObjectStorageFiles, err error) (bool, error) {
+	class := classifyError(err)
+	if class == ErrorInsufficientFunds {
+		run.logger.Warn("wallet cannot pay for uploads, pausing uploads until it is topped up", "error", err)
+		run.setPaused(true)
+		return true, nil
+	}
+
+	run.failures++
+	for _, object := range objects {
+		run.logger.Error("unable to sync object", "object", object.Key, "class", class, "consecutive_failures", run.failures, "error", err)
+	}
+
+	if run.retry.deadLetter && len(objects) == 1 && class != ErrorAmbiguous {
+		deadLetterErr := run.deadLetter(objects[0], class, err)
+		if deadLetterErr != nil {
+			return true, deadLetterErr
+		}
+	}
+
+	if run.failures >= run.retry.failureThreshold {
+		return true, &giveUpError{reason: fmt.Sprintf("giving up after %d consecutive failed uploads", run.failures), err: err}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,114 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/the-singularity-labs/cornelius/arweave"
+	"github.com/the-singularity-labs/cornelius/log"
+)
+
+func TestClassifyError(t *testing.T) {
+	timedOut := fmt.Errorf("unable to exec ardrive cli upload command: %w", fmt.Errorf("%w after 1m0s", errCommandTimedOut))
+
+	for err, expected := range map[error]string{
+		errors.New("socket hang up"):                     ErrorTransient,
+		timedOut:                                         ErrorTransient,
+		&ambiguousUploadError{err: timedOut}:             ErrorAmbiguous,
+		errors.New("Insufficient funds for this upload"): ErrorInsufficientFunds,
+		&arweave.StatusError{StatusCode: 402}:            ErrorInsufficientFunds,
+		&arweave.StatusError{StatusCode: 503}:            ErrorTransient,
+		&arweave.StatusError{StatusCode: 400}:            ErrorPermanent,
+		&giveUpError{reason: "giving up", err: timedOut}: ErrorPermanent,
+		errors.New("invalid drive id"):                   ErrorPermanent,
+	} {
+		if class := classifyError(err); class != expected {
+			t.Errorf("expected %q to be %s, got %s", err, expected, class)
+		}
+	}
+}
+
+func TestUploadFailedCountsBundlesOnce(t *testing.T) {
+	policy, err := newRetryPolicy(RetryConfig{FailureThreshold: 2, DeadLetter: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := NewStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := &pipelineRun{
+		pipeline: Pipeline{Name: "site"},
+		logger:   log.NewTextLogger(slog.LevelError),
+		state:    state,
+		retry:    policy,
+	}
+
+	bundle := ObjectStorageFiles{{Key: "a.txt"}, {Key: "b.txt"}, {Key: "c.txt"}}
+	stop, err := run.uploadFailed(bundle, errors.New("bundle rejected"))
+	if stop || err != nil {
+		t.Fatalf("expected one failed bundle to stay below the threshold, got %v, %v", stop, err)
+	}
+	if len(state.DeadLetters("site")) != 0 {
+		t.Error("expected the objects of a failed bundle not to be dead-lettered")
+	}
+
+	// uploads that may have succeeded are left for the next iteration
+	stop, err = run.uploadFailed(ObjectStorageFiles{{Key: "d.txt"}}, &ambiguousUploadError{err: errCommandTimedOut})
+	if !stop || err == nil || classifyError(err) != ErrorPermanent {
+		t.Errorf("expected the pipeline to give up after the second failed upload, got %v, %v", stop, err)
+	}
+	if len(state.DeadLetters("site")) != 0 {
+		t.Error("expected an ambiguous upload not to be dead-lettered")
+	}
+}
+
+func TestTimedOutUpload(t *testing.T) {
+	dir := t.TempDir()
+	listing := `[{"path": "/drive/docs/index.html", "entityType": "file", "entityId": "entity", "dataTxId": "data-tx", "lastModifiedDate": %d}]`
+	script := filepath.Join(dir, "ardrive")
+	err := os.WriteFile(script, []byte(fmt.Sprintf("#!/bin/sh\ncat <<'EOF'\n%s\nEOF\n", fmt.Sprintf(listing, time.Now().Unix()))), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ardriveClient, err := NewArdriveClient(log.NewTextLogger(slog.LevelError), script, GatewayConfig{}, UploadConfig{}, "", "", "drive", "parent", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := NewStateStore(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := &pipelineRun{
+		pipeline:      Pipeline{Name: "site", Receipts: &ReceiptsConfig{Path: filepath.Join(dir, "receipts")}},
+		logger:        log.NewTextLogger(slog.LevelError),
+		ardriveClient: ardriveClient,
+		parentPath:    "/drive",
+		state:         state,
+	}
+
+	lastModified := time.Now().Add(-time.Hour)
+	err = run.timedOutUpload(ObjectStorageFile{Key: "docs/index.html", Size: 42, LastModified: lastModified}, LocalFile{Sha256: "hash"}, "", errCommandTimedOut)
+	if err != nil {
+		t.Fatalf("expected the listed upload to be recorded, got %v", err)
+	}
+	fileState, exists := state.File("site", "docs/index.html")
+	if !exists || fileState.EntityId != "entity" || fileState.DataTxId != "data-tx" || fileState.UploadedAt.IsZero() {
+		t.Errorf("expected the upload to be recorded, got %+v", fileState)
+	}
+	if len(run.receipts) != 1 || run.receipts[0].DataTxId != "data-tx" {
+		t.Errorf("expected a receipt of the upload, got %+v", run.receipts)
+	}
+
+	err = run.timedOutUpload(ObjectStorageFile{Key: "docs/missing.html", LastModified: lastModified}, LocalFile{}, "", errCommandTimedOut)
+	if classifyError(err) != ErrorAmbiguous || !strings.Contains(err.Error(), "command timed out") {
+		t.Errorf("expected an upload missing from the drive to be ambiguous, got %v", err)
+	}
+}
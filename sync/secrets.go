@@ -0,0 +1,166 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SecretProvider resolves a reference (the part of a config value after the
+// "<scheme>:" prefix) into the secret it points to.
+type SecretProvider interface {
+	Resolve(ctx context.Context, ref string) ([]byte, error)
+}
+
+type SecretsConfig struct {
+	Vault    *VaultConfig    `yaml:"vault"`
+	Keystore *KeystoreConfig `yaml:"keystore"`
+}
+
+type SecretResolver struct {
+	providers map[string]SecretProvider
+}
+
+func NewSecretResolver(config SecretsConfig) (*SecretResolver, error) {
+	resolver := &SecretResolver{
+		providers: map[string]SecretProvider{
+			"env":  envSecretProvider{},
+			"file": fileSecretProvider{},
+		},
+	}
+
+	if config.Vault != nil {
+		vault, err := NewVaultSecretProvider(*config.Vault)
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize vault secret provider: %w", err)
+		}
+		resolver.providers["vault"] = vault
+	}
+
+	if config.Keystore != nil {
+		keystore, err := NewKeystoreSecretProvider(*config.Keystore)
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize keystore secret provider: %w", err)
+		}
+		resolver.providers["keystore"] = keystore
+	}
+
+	return resolver, nil
+}
+
+func (resolver *SecretResolver) parse(value string) (SecretProvider, string, bool) {
+	scheme, ref, found := strings.Cut(value, ":")
+	if !found {
+		return nil, "", false
+	}
+
+	provider, exists := resolver.providers[scheme]
+	if !exists {
+		return nil, "", false
+	}
+
+	return provider, ref, true
+}
+
+// Resolve returns the secret referenced by value. Values without a known
+// "<scheme>:" prefix are returned unchanged so plain config keeps working.
+func (resolver *SecretResolver) Resolve(ctx context.Context, value string) (string, error) {
+	provider, ref, isRef := resolver.parse(value)
+	if !isRef {
+		return value, nil
+	}
+
+	secret, err := provider.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve secret %q: %w", value, err)
+	}
+
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// ResolveFile returns a path to a file holding the secret referenced by value.
+// Plain paths are returned as is, otherwise the secret is written with 0600
+// permissions to a memory backed directory and removed again by the returned
+// cleanup func. Secrets are never written to disk, so resolving fails on
+// hosts without a memory backed directory.
+func (resolver *SecretResolver) ResolveFile(ctx context.Context, value string) (string, func(), error) {
+	provider, ref, isRef := resolver.parse(value)
+	if !isRef {
+		return value, func() {}, nil
+	}
+
+	if _, isFile := provider.(fileSecretProvider); isFile {
+		return ref, func() {}, nil
+	}
+
+	secret, err := provider.Resolve(ctx, ref)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to resolve secret %q: %w", value, err)
+	}
+
+	dir, err := secretDirectory()
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to write secret %q to a file: %w", value, err)
+	}
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to create secret directory %q: %w", dir, err)
+	}
+
+	file, err := os.CreateTemp(dir, "secret-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to create secret file: %w", err)
+	}
+	defer file.Close()
+
+	cleanup := func() {
+		os.Remove(file.Name())
+	}
+
+	err = file.Chmod(0600)
+	if err == nil {
+		_, err = file.Write(secret)
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("unable to write secret file: %w", err)
+	}
+
+	return file.Name(), cleanup, nil
+}
+
+// memoryDirectory is the memory backed directory secret files are written to.
+var memoryDirectory = "/dev/shm"
+
+func secretDirectory() (string, error) {
+	info, err := os.Stat(memoryDirectory)
+	if err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%s is not available to keep the secret off disk, reference a wallet file with file: instead", memoryDirectory)
+	}
+
+	return filepath.Join(memoryDirectory, "cornelius"), nil
+}
+
+type envSecretProvider struct{}
+
+func (envSecretProvider) Resolve(ctx context.Context, ref string) ([]byte, error) {
+	value, exists := os.LookupEnv(ref)
+	if !exists {
+		return nil, fmt.Errorf("environment variable %q is not set", ref)
+	}
+
+	return []byte(value), nil
+}
+
+type fileSecretProvider struct{}
+
+func (fileSecretProvider) Resolve(ctx context.Context, ref string) ([]byte, error) {
+	contents, err := os.ReadFile(ref)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read secret file %q: %w", ref, err)
+	}
+
+	return contents, nil
+}
@@ -0,0 +1,218 @@
+package sync
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	DefaultKeystorePassphraseEnv = "CORNELIUS_KEYSTORE_PASSPHRASE"
+
+	keystoreVersion = 1
+	keystoreScryptN = 1 << 15
+	keystoreScryptR = 8
+	keystoreScryptP = 1
+	keystoreKeyLen  = 32
+)
+
+type KeystoreConfig struct {
+	Path           string `yaml:"path"`
+	PassphraseEnv  string `yaml:"passphrase_env"`
+	PassphrasePath string `yaml:"passphrase_path"`
+}
+
+// KeystoreSecretProvider reads secrets from a local file encrypted with
+// AES-256-GCM under a scrypt derived key. References are entry names.
+type KeystoreSecretProvider struct {
+	path       string
+	passphrase []byte
+}
+
+type keystoreEnvelope struct {
+	Version    int    `json:"version"`
+	Kdf        string `json:"kdf"`
+	Salt       []byte `json:"salt"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func NewKeystoreSecretProvider(config KeystoreConfig) (*KeystoreSecretProvider, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("keystore path is not set")
+	}
+
+	passphrase := []byte{}
+	if config.PassphrasePath != "" {
+		contents, err := os.ReadFile(config.PassphrasePath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read keystore passphrase file %q: %w", config.PassphrasePath, err)
+		}
+		passphrase = []byte(strings.TrimSpace(string(contents)))
+	} else {
+		passphraseEnv := config.PassphraseEnv
+		if passphraseEnv == "" {
+			passphraseEnv = DefaultKeystorePassphraseEnv
+		}
+		passphrase = []byte(os.Getenv(passphraseEnv))
+	}
+
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("keystore passphrase is not set")
+	}
+
+	return &KeystoreSecretProvider{
+		path:       config.Path,
+		passphrase: passphrase,
+	}, nil
+}
+
+func (keystore *KeystoreSecretProvider) Resolve(ctx context.Context, ref string) ([]byte, error) {
+	entries, err := keystore.load()
+	if err != nil {
+		return nil, err
+	}
+
+	secret, exists := entries[ref]
+	if !exists {
+		return nil, fmt.Errorf("entry %q not found in keystore %q", ref, keystore.path)
+	}
+
+	return secret, nil
+}
+
+// Put stores secret under name, creating the keystore if it does not exist.
+func (keystore *KeystoreSecretProvider) Put(name string, secret []byte) error {
+	entries, err := keystore.load()
+	if errors.Is(err, os.ErrNotExist) {
+		entries = map[string][]byte{}
+	} else if err != nil {
+		return err
+	}
+
+	entries[name] = secret
+
+	return keystore.save(entries)
+}
+
+func (keystore *KeystoreSecretProvider) load() (map[string][]byte, error) {
+	contents, err := os.ReadFile(keystore.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read keystore %q: %w", keystore.path, err)
+	}
+
+	envelope := keystoreEnvelope{}
+	err = json.Unmarshal(contents, &envelope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse keystore %q: %w", keystore.path, err)
+	}
+
+	if envelope.Version != keystoreVersion || envelope.Kdf != "scrypt" {
+		return nil, fmt.Errorf("unsupported keystore version %d (%s)", envelope.Version, envelope.Kdf)
+	}
+
+	key, err := scrypt.Key(keystore.passphrase, envelope.Salt, envelope.N, envelope.R, envelope.P, keystoreKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("unable to derive keystore key: %w", err)
+	}
+
+	gcm, err := newKeystoreCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, envelope.Nonce, envelope.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt keystore %q, passphrase may be wrong: %w", keystore.path, err)
+	}
+
+	entries := map[string][]byte{}
+	err = json.Unmarshal(plaintext, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse keystore entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+func (keystore *KeystoreSecretProvider) save(entries map[string][]byte) error {
+	plaintext, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("unable to encode keystore entries: %w", err)
+	}
+
+	envelope := keystoreEnvelope{
+		Version: keystoreVersion,
+		Kdf:     "scrypt",
+		Salt:    make([]byte, 16),
+		N:       keystoreScryptN,
+		R:       keystoreScryptR,
+		P:       keystoreScryptP,
+	}
+
+	_, err = rand.Read(envelope.Salt)
+	if err != nil {
+		return fmt.Errorf("unable to generate keystore salt: %w", err)
+	}
+
+	key, err := scrypt.Key(keystore.passphrase, envelope.Salt, envelope.N, envelope.R, envelope.P, keystoreKeyLen)
+	if err != nil {
+		return fmt.Errorf("unable to derive keystore key: %w", err)
+	}
+
+	gcm, err := newKeystoreCipher(key)
+	if err != nil {
+		return err
+	}
+
+	envelope.Nonce = make([]byte, gcm.NonceSize())
+	_, err = rand.Read(envelope.Nonce)
+	if err != nil {
+		return fmt.Errorf("unable to generate keystore nonce: %w", err)
+	}
+	envelope.Ciphertext = gcm.Seal(nil, envelope.Nonce, plaintext, nil)
+
+	contents, err := json.MarshalIndent(envelope, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode keystore: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(keystore.path), 0700)
+	if err != nil {
+		return fmt.Errorf("unable to create keystore directory: %w", err)
+	}
+
+	tmpPath := keystore.path + ".tmp"
+	err = os.WriteFile(tmpPath, contents, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to write keystore %q: %w", keystore.path, err)
+	}
+
+	return os.Rename(tmpPath, keystore.path)
+}
+
+func newKeystoreCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize keystore cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize keystore cipher: %w", err)
+	}
+
+	return gcm, nil
+}
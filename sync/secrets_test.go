@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeystoreSecretProviderRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	passphrasePath := filepath.Join(dir, "passphrase")
+	err := os.WriteFile(passphrasePath, []byte("correct horse battery staple\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := KeystoreConfig{Path: filepath.Join(dir, "keystore.json"), PassphrasePath: passphrasePath}
+	keystore, err := NewKeystoreSecretProvider(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wallet := []byte(`{"kty":"RSA","n":"abc"}`)
+	err = keystore.Put("wallet", wallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := os.ReadFile(config.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(contents, []byte(`"kty"`)) {
+		t.Error("expected the keystore to be encrypted")
+	}
+
+	reopened, err := NewKeystoreSecretProvider(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := reopened.Resolve(context.Background(), "wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret, wallet) {
+		t.Errorf("expected %s, got %s", wallet, secret)
+	}
+
+	wrongPassphrase := &KeystoreSecretProvider{path: config.Path, passphrase: []byte("wrong")}
+	_, err = wrongPassphrase.Resolve(context.Background(), "wallet")
+	if err == nil {
+		t.Error("expected a wrong passphrase to fail")
+	}
+}
+
+func TestSecretResolver(t *testing.T) {
+	t.Setenv("CORNELIUS_TEST_SECRET", " s3cr3t\n")
+
+	server := newVaultStub(t, map[string]string{
+		"/v1/secret/data/cornelius/arweave": `{"data": {"data": {"wallet": {"kty": "RSA"}}}}`,
+	})
+
+	resolver, err := NewSecretResolver(SecretsConfig{
+		Vault: &VaultConfig{Address: server.URL, Token: "test-token", Namespace: "team"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for value, expected := range map[string]string{
+		"env:CORNELIUS_TEST_SECRET": "s3cr3t",
+		"plain-value":               "plain-value",
+		"unknown:scheme":            "unknown:scheme",
+	} {
+		resolved, err := resolver.Resolve(context.Background(), value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolved != expected {
+			t.Errorf("expected %q to resolve to %q, got %q", value, expected, resolved)
+		}
+	}
+
+	memoryDirectory = t.TempDir()
+	defer func() {
+		memoryDirectory = "/dev/shm"
+	}()
+
+	walletPath, cleanup, err := resolver.ResolveFile(context.Background(), "vault:cornelius/arweave#wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := os.ReadFile(walletPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != `{"kty":"RSA"}` {
+		t.Errorf("expected the wallet to be written, got %s", contents)
+	}
+	info, err := os.Stat(walletPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected wallet file permissions 0600, got %v", info.Mode().Perm())
+	}
+
+	cleanup()
+	_, err = os.Stat(walletPath)
+	if !os.IsNotExist(err) {
+		t.Errorf("expected the wallet file to be removed, got %v", err)
+	}
+
+	// without a memory backed directory the wallet is not written to disk
+	memoryDirectory = filepath.Join(t.TempDir(), "shm")
+	_, _, err = resolver.ResolveFile(context.Background(), "vault:cornelius/arweave#wallet")
+	if err == nil || !strings.Contains(err.Error(), "is not available") {
+		t.Errorf("expected resolving the wallet file to fail, got %v", err)
+	}
+	walletPath, _, err = resolver.ResolveFile(context.Background(), "wallet.json")
+	if err != nil || walletPath != "wallet.json" {
+		t.Errorf("expected plain wallet paths to be returned as is, got %q, %v", walletPath, err)
+	}
+
+	_, err = resolver.Resolve(context.Background(), "vault:cornelius/arweave#missing")
+	if err == nil || !strings.Contains(err.Error(), "vault:cornelius/arweave#missing") {
+		t.Errorf("expected an error naming the secret, got %v", err)
+	}
+}
+
+func TestMaskPassword(t *testing.T) {
+	args := []string{"upload-file", "--unsafe-drive-password", "hunter2", "--drive-id", "abc"}
+	masked := maskPassword(args)
+
+	if !reflect.DeepEqual(masked, []string{"upload-file", "--unsafe-drive-password", "***", "--drive-id", "abc"}) {
+		t.Errorf("unexpected masked args %v", masked)
+	}
+	if args[2] != "hunter2" {
+		t.Error("expected the original args to be left untouched")
+	}
+}
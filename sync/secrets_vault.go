@@ -0,0 +1,166 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultVaultMount = "secret"
+
+type VaultConfig struct {
+	Address   string   `yaml:"address"`
+	Token     string   `yaml:"token"`
+	TokenPath string   `yaml:"token_path"`
+	Namespace string   `yaml:"namespace"`
+	Mount     string   `yaml:"mount"`
+	KVVersion int      `yaml:"kv_version"`
+	Timeout   Duration `yaml:"timeout"`
+}
+
+// VaultSecretProvider reads secrets from a HashiCorp Vault KV engine.
+// References take the form "<path>#<field>", e.g. "cornelius/arweave#wallet".
+type VaultSecretProvider struct {
+	httpClient *http.Client
+	address    string
+	token      string
+	namespace  string
+	mount      string
+	kvVersion  int
+}
+
+func NewVaultSecretProvider(config VaultConfig) (*VaultSecretProvider, error) {
+	address := config.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return nil, fmt.Errorf("vault address is not set")
+	}
+
+	token := config.Token
+	if token == "" && config.TokenPath != "" {
+		contents, err := os.ReadFile(config.TokenPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read vault token file %q: %w", config.TokenPath, err)
+		}
+		token = strings.TrimSpace(string(contents))
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("vault token is not set")
+	}
+
+	mount := config.Mount
+	if mount == "" {
+		mount = defaultVaultMount
+	}
+
+	kvVersion := config.KVVersion
+	if kvVersion == 0 {
+		kvVersion = 2
+	} else if kvVersion != 1 && kvVersion != 2 {
+		return nil, fmt.Errorf("%d is not a valid vault kv version", kvVersion)
+	}
+
+	timeout := time.Duration(config.Timeout)
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &VaultSecretProvider{
+		httpClient: &http.Client{Timeout: timeout},
+		address:    strings.TrimSuffix(address, "/"),
+		token:      token,
+		namespace:  config.Namespace,
+		mount:      strings.Trim(mount, "/"),
+		kvVersion:  kvVersion,
+	}, nil
+}
+
+func (vault *VaultSecretProvider) Resolve(ctx context.Context, ref string) ([]byte, error) {
+	secretPath, field, found := strings.Cut(ref, "#")
+	if !found || field == "" {
+		return nil, fmt.Errorf("vault reference %q must be of the form <path>#<field>", ref)
+	}
+	secretPath = strings.Trim(secretPath, "/")
+
+	url := fmt.Sprintf("%s/v1/%s/%s", vault.address, vault.mount, secretPath)
+	if vault.kvVersion == 2 {
+		url = fmt.Sprintf("%s/v1/%s/data/%s", vault.address, vault.mount, secretPath)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", vault.token)
+	if vault.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", vault.namespace)
+	}
+
+	resp, err := vault.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read vault response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %s for %q", resp.Status, secretPath)
+	}
+
+	var payload struct {
+		Data json.RawMessage `json:"data"`
+	}
+	err = json.Unmarshal(body, &payload)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse vault response: %w", err)
+	}
+
+	data := payload.Data
+	if vault.kvVersion == 2 {
+		var versioned struct {
+			Data json.RawMessage `json:"data"`
+		}
+		err = json.Unmarshal(data, &versioned)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse vault kv v2 response: %w", err)
+		}
+		data = versioned.Data
+	}
+
+	fields := map[string]any{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse vault secret data: %w", err)
+	}
+
+	value, exists := fields[field]
+	if !exists {
+		return nil, fmt.Errorf("field %q not found in vault secret %q", field, secretPath)
+	}
+
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	default:
+		// wallets stored as JSON objects rather than strings are re-encoded
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode field %q of vault secret %q: %w", field, secretPath, err)
+		}
+		return encoded, nil
+	}
+}
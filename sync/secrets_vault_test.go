@@ -0,0 +1,118 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newVaultStub serves the given secrets, keyed by request path, and checks
+// the token and namespace headers of every request.
+func newVaultStub(t *testing.T, secrets map[string]string) *httptest.Server {
+	t.Helper()
+
+	return newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Header.Get("X-Vault-Namespace") != "team" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		body, exists := secrets[r.URL.Path]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	})
+}
+
+func TestVaultSecretProviderKVv2(t *testing.T) {
+	server := newVaultStub(t, map[string]string{
+		"/v1/secret/data/cornelius/arweave": `{"data": {"data": {"password": "hunter2", "wallet": {"kty": "RSA", "n": "abc"}}, "metadata": {"version": 3}}}`,
+	})
+
+	vault, err := NewVaultSecretProvider(VaultConfig{Address: server.URL + "/", Token: "test-token", Namespace: "team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	password, err := vault.Resolve(context.Background(), "/cornelius/arweave#password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(password) != "hunter2" {
+		t.Errorf("expected password %q, got %q", "hunter2", password)
+	}
+
+	wallet, err := vault.Resolve(context.Background(), "cornelius/arweave#wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(wallet) != `{"kty":"RSA","n":"abc"}` {
+		t.Errorf("expected the wallet object to be re-encoded, got %s", wallet)
+	}
+
+	_, err = vault.Resolve(context.Background(), "cornelius/arweave#missing")
+	if err == nil || !strings.Contains(err.Error(), `field "missing" not found`) {
+		t.Errorf("expected a missing field error, got %v", err)
+	}
+}
+
+func TestVaultSecretProviderKVv1(t *testing.T) {
+	server := newVaultStub(t, map[string]string{
+		"/v1/kv/cornelius/bucket": `{"data": {"secret_key": "s3cr3t"}}`,
+	})
+
+	vault, err := NewVaultSecretProvider(VaultConfig{Address: server.URL, Token: "test-token", Namespace: "team", Mount: "/kv/", KVVersion: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secretKey, err := vault.Resolve(context.Background(), "cornelius/bucket#secret_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secretKey) != "s3cr3t" {
+		t.Errorf("expected secret key %q, got %q", "s3cr3t", secretKey)
+	}
+}
+
+func TestVaultSecretProviderErrors(t *testing.T) {
+	server := newVaultStub(t, map[string]string{})
+
+	vault, err := NewVaultSecretProvider(VaultConfig{Address: server.URL, Token: "test-token", Namespace: "team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = vault.Resolve(context.Background(), "cornelius/arweave")
+	if err == nil || !strings.Contains(err.Error(), "must be of the form") {
+		t.Errorf("expected a reference error, got %v", err)
+	}
+
+	_, err = vault.Resolve(context.Background(), "cornelius/unknown#password")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	forbidden, err := NewVaultSecretProvider(VaultConfig{Address: server.URL, Token: "wrong-token", Namespace: "team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = forbidden.Resolve(context.Background(), "cornelius/arweave#password")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected a forbidden error, got %v", err)
+	}
+
+	_, err = NewVaultSecretProvider(VaultConfig{Address: server.URL, Token: "test-token", KVVersion: 3})
+	if err == nil {
+		t.Error("expected kv version 3 to be rejected")
+	}
+}
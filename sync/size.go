@@ -0,0 +1,23 @@
+package sync
+
+import (
+	"github.com/dustin/go-humanize"
+	"gopkg.in/yaml.v3"
+)
+
+// ByteSize accepts plain byte counts as well as human readable sizes like "10MB" or "2GiB".
+type ByteSize int64
+
+func (b *ByteSize) UnmarshalYAML(value *yaml.Node) error {
+	var str string
+	if err := value.Decode(&str); err != nil {
+		return err
+	}
+
+	tmp, err := humanize.ParseBytes(str)
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(tmp)
+	return nil
+}
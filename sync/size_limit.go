@@ -0,0 +1,153 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	OversizeSkip  = "skip"
+	OversizeFail  = "fail"
+	OversizeSplit = "split"
+
+	splitPartsSuffix = ".parts"
+)
+
+type SkippedObject struct {
+	Key    string
+	Reason string
+}
+
+type SkippedObjects []SkippedObject
+
+// Summary counts the skipped objects per reason.
+func (skipped SkippedObjects) Summary() map[string]int {
+	summary := map[string]int{}
+	for _, object := range skipped {
+		summary[object.Reason]++
+	}
+
+	return summary
+}
+
+func (skipped SkippedObjects) Keys() []string {
+	keys := []string{}
+	for _, object := range skipped {
+		keys = append(keys, object.Key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func sizeLimitPolicy(pipeline Pipeline) (int64, string, error) {
+	maxFileSize := int64(pipeline.MaxFileSize)
+	if maxFileSize == 0 {
+		maxFileSize = ArdriveCliFileSizeLimit
+	}
+
+	onOversize := pipeline.OnOversize
+	switch onOversize {
+	case "":
+		onOversize = OversizeSkip
+	case OversizeSkip, OversizeFail, OversizeSplit:
+	default:
+		return 0, "", fmt.Errorf("%q is not a valid on_oversize policy", onOversize)
+	}
+
+	if maxFileSize > ArdriveCliFileSizeLimit {
+		return 0, "", fmt.Errorf("max_file_size %d exceeds the ardrive cli limit of %d bytes", maxFileSize, ArdriveCliFileSizeLimit)
+	}
+
+	return maxFileSize, onOversize, nil
+}
+
+// splitPartsKey is the name of the folder holding the parts of a split
+// object. The folder is created right below the parent folder, so the prefix
+// of the key is kept in its name, escaped, to tell apart objects of the same
+// name under different prefixes.
+func splitPartsKey(key string) string {
+	return strings.NewReplacer("%", "%25", "/", "%2F").Replace(key) + splitPartsSuffix
+}
+
+func splitPartName(filename string, part int) string {
+	return fmt.Sprintf("%s.part%04d", filename, part)
+}
+
+// splitLocalFile writes the file in parts of at most partSize bytes into a
+// sibling folder, which is uploaded as a whole in place of the original file.
+func splitLocalFile(localFile LocalFile, partSize int64) (LocalFile, error) {
+	source, err := os.Open(localFile.Path)
+	if err != nil {
+		return LocalFile{}, fmt.Errorf("unable to open %q for splitting: %w", localFile.Path, err)
+	}
+	defer source.Close()
+
+	partsDir := splitPartsKey(localFile.Path)
+	err = os.MkdirAll(partsDir, 0700)
+	if err != nil {
+		return LocalFile{}, fmt.Errorf("unable to create parts folder %q: %w", partsDir, err)
+	}
+
+	firstPartPath := ""
+	for part := 1; ; part++ {
+		partPath := filepath.Join(partsDir, splitPartName(localFile.Filename(), part))
+		written, err := copyPart(partPath, source, partSize)
+		if err != nil {
+			os.RemoveAll(partsDir)
+			return LocalFile{}, err
+		}
+
+		if written == 0 {
+			os.Remove(partPath)
+			break
+		}
+
+		if firstPartPath == "" {
+			firstPartPath = partPath
+		}
+
+		if written < partSize {
+			break
+		}
+	}
+
+	return LocalFile{
+		Dir:      partsDir,
+		Path:     firstPartPath,
+		Mimetype: "application/octet-stream",
+	}, nil
+}
+
+func copyPart(partPath string, source io.Reader, partSize int64) (int64, error) {
+	part, err := os.Create(partPath)
+	if err != nil {
+		return 0, fmt.Errorf("unable to create part %q: %w", partPath, err)
+	}
+	defer part.Close()
+
+	written, err := io.CopyN(part, source, partSize)
+	if err != nil && err != io.EOF {
+		return 0, fmt.Errorf("unable to write part %q: %w", partPath, err)
+	}
+
+	return written, nil
+}
+
+// ardrivePathForObject is the path an object is expected at on the drive,
+// which for split objects is their first part. The parts folder is uploaded
+// with upload-file --local-path, which creates it right below the parent
+// folder whatever the prefix of the object.
+func ardrivePathForObject(parentPath string, objectStorageFile ObjectStorageFile, splitSize int64) string {
+	if splitSize > 0 && objectStorageFile.Size > splitSize {
+		filename := path.Base(objectStorageFile.Key)
+		return filepath.Join(parentPath, splitPartsKey(objectStorageFile.Key), splitPartName(filename, 1))
+	}
+
+	return filepath.Join(parentPath, objectStorageFile.Key)
+}
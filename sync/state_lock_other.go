@@ -0,0 +1,9 @@
+//go:build !unix
+
+package sync
+
+// lockFile is a no-op where flock is not available, the state is then only
+// safe from concurrent updates within a process.
+func (store *StateStore) lockFile() (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on the lock file of the state, shared
+// with other processes using the same state file, e.g. `cornelius retry`.
+func (store *StateStore) lockFile() (func(), error) {
+	err := os.MkdirAll(filepath.Dir(store.path), 0700)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create state directory: %w", err)
+	}
+
+	lock, err := os.OpenFile(store.path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open state lock: %w", err)
+	}
+
+	err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("unable to lock state file %q: %w", store.path, err)
+	}
+
+	return func() {
+		syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+		lock.Close()
+	}, nil
+}
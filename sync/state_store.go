@@ -0,0 +1,447 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	gosync "sync"
+	"time"
+)
+
+// DefaultStateSaveInterval is how long the synchronizer batches updates of
+// the state before writing the file.
+const DefaultStateSaveInterval = Duration(5 * time.Second)
+
+// StateStore persists what has been synced per pipeline and object key to a
+// JSON file. A nil *StateStore is valid and records nothing. Changes made to
+// the file by another process, e.g. `cornelius retry`, are picked up before
+// the state is next read or updated. Updates hold a file lock across reading
+// and writing the file so no process overwrites the changes of another.
+//
+// Every update rewrites the whole file, unless saves are batched: updates
+// are then written at most once per save interval and on Flush, and replayed
+// on top of changes other processes made in between.
+type StateStore struct {
+	path         string
+	mutex        gosync.Mutex
+	state        syncState
+	modTime      time.Time
+	size         int64
+	saveInterval time.Duration
+	savedAt      time.Time
+	pending      []func()
+}
+
+type syncState struct {
+	Pipelines map[string]*PipelineState `json:"pipelines"`
+}
+
+type PipelineState struct {
+	Files       map[string]*FileState   `json:"files"`
+	Manifest    *ManifestState          `json:"manifest,omitempty"`
+	Arns        *ArnsState              `json:"arns,omitempty"`
+	DeadLetters map[string]*DeadLetter  `json:"dead_letters,omitempty"`
+	Folders     map[string]*FolderState `json:"folders,omitempty"`
+	Receipts    []Receipt               `json:"receipts,omitempty"`
+	WriteBacks  []Receipt               `json:"write_backs,omitempty"`
+}
+
+type ManifestState struct {
+	EntityId  string    `json:"entity_id"`
+	TxId      string    `json:"tx_id"`
+	Paths     int       `json:"paths"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ArnsState struct {
+	ProcessId string    `json:"process_id"`
+	Undername string    `json:"undername"`
+	TxId      string    `json:"tx_id"`
+	MessageId string    `json:"message_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DeadLetter records an object that failed to upload after all retries. It
+// is skipped until it is retried or cleared through the CLI.
+type DeadLetter struct {
+	Key       string    `json:"key"`
+	Reason    string    `json:"reason"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+// FolderState records a folder created in a bundle, so files uploaded before
+// the gateway indexed it are placed in it rather than in a second folder of
+// the same name.
+type FolderState struct {
+	EntityId  string `json:"entity_id"`
+	BundledIn string `json:"bundled_in"`
+}
+
+type FileState struct {
+	Key           string    `json:"key"`
+	Size          int64     `json:"size,omitempty"`
+	Sha256        string    `json:"sha256,omitempty"`
+	IpfsCid       string    `json:"ipfs_cid,omitempty"`
+	EntityId      string    `json:"entity_id,omitempty"`
+	DataTxId      string    `json:"data_tx_id,omitempty"`
+	MetadataTxId  string    `json:"metadata_tx_id,omitempty"`
+	BundledIn     string    `json:"bundled_in,omitempty"`
+	DrivePath     string    `json:"drive_path,omitempty"`
+	UploadedAt    time.Time `json:"uploaded_at,omitempty"`
+	Confirmations int       `json:"confirmations,omitempty"`
+	ConfirmedAt   time.Time `json:"confirmed_at,omitempty"`
+	Dropped       bool      `json:"dropped,omitempty"`
+	Resubmissions int       `json:"resubmissions,omitempty"`
+	Verification  string    `json:"verification,omitempty"`
+	VerifiedAt    time.Time `json:"verified_at,omitempty"`
+}
+
+// markUploaded records a new upload of the object, restarting its
+// confirmation tracking and verification.
+func (fileState *FileState) markUploaded(uploadedAt time.Time) {
+	if fileState.Dropped {
+		fileState.Resubmissions++
+	}
+
+	fileState.UploadedAt = uploadedAt
+	fileState.DrivePath = ""
+	fileState.Confirmations = 0
+	fileState.ConfirmedAt = time.Time{}
+	fileState.Dropped = false
+	fileState.Verification = ""
+	fileState.VerifiedAt = time.Time{}
+}
+
+func NewStateStore(path string) (*StateStore, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	store := &StateStore{
+		path:  path,
+		state: syncState{Pipelines: map[string]*PipelineState{}},
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read state file %q: %w", path, err)
+	}
+
+	err = store.load(info)
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (store *StateStore) load(info os.FileInfo) error {
+	contents, err := os.ReadFile(store.path)
+	if err != nil {
+		return fmt.Errorf("unable to read state file %q: %w", store.path, err)
+	}
+
+	state := syncState{}
+	err = json.Unmarshal(contents, &state)
+	if err != nil {
+		return fmt.Errorf("unable to parse state file %q: %w", store.path, err)
+	}
+
+	if state.Pipelines == nil {
+		state.Pipelines = map[string]*PipelineState{}
+	}
+
+	store.state = state
+	store.modTime = info.ModTime()
+	store.size = info.Size()
+
+	return nil
+}
+
+// refresh reloads the state file when it was changed by another process,
+// keeping the current state when it cannot be read. Updates not saved yet
+// are applied again on top of the reloaded state.
+func (store *StateStore) refresh() {
+	info, err := os.Stat(store.path)
+	if err != nil || (info.ModTime().Equal(store.modTime) && info.Size() == store.size) {
+		return
+	}
+
+	err = store.load(info)
+	if err != nil {
+		return
+	}
+	for _, update := range store.pending {
+		update()
+	}
+}
+
+// batchSaves makes updates be written at most once per interval. Updates
+// made since the last save are lost if the process dies before Flush.
+func (store *StateStore) batchSaves(interval time.Duration) {
+	if store == nil {
+		return
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.saveInterval = interval
+}
+
+// UpdateFile applies update to the state of the object and persists the result.
+func (store *StateStore) UpdateFile(pipelineName, key string, update func(*FileState)) error {
+	if store == nil {
+		return nil
+	}
+
+	return store.apply(func() {
+		pipelineState := store.pipelineState(pipelineName)
+		fileState, exists := pipelineState.Files[key]
+		if !exists {
+			fileState = &FileState{Key: key}
+			pipelineState.Files[key] = fileState
+		}
+		update(fileState)
+	})
+}
+
+// UpdatePipeline applies update to the state of the pipeline and persists the result.
+func (store *StateStore) UpdatePipeline(pipelineName string, update func(*PipelineState)) error {
+	if store == nil {
+		return nil
+	}
+
+	return store.apply(func() {
+		update(store.pipelineState(pipelineName))
+	})
+}
+
+// apply applies update to the state and saves it, unless the last save was
+// less than the save interval ago.
+func (store *StateStore) apply(update func()) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.saveInterval == 0 {
+		unlock, err := store.lockFile()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		store.refresh()
+
+		update()
+		return store.save()
+	}
+
+	store.refresh()
+	update()
+	store.pending = append(store.pending, update)
+	if time.Since(store.savedAt) < store.saveInterval {
+		return nil
+	}
+
+	return store.flush()
+}
+
+// Flush saves the updates batched since the last save.
+func (store *StateStore) Flush() error {
+	if store == nil {
+		return nil
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.flush()
+}
+
+func (store *StateStore) flush() error {
+	if len(store.pending) == 0 {
+		return nil
+	}
+
+	unlock, err := store.lockFile()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	store.refresh()
+
+	err = store.save()
+	if err != nil {
+		return err
+	}
+	store.pending = nil
+	store.savedAt = time.Now()
+
+	return nil
+}
+
+// File returns a copy of the state of the object.
+func (store *StateStore) File(pipelineName, key string) (FileState, bool) {
+	if store == nil {
+		return FileState{}, false
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.refresh()
+
+	fileState, exists := store.pipelineState(pipelineName).Files[key]
+	if !exists {
+		return FileState{}, false
+	}
+
+	return *fileState, true
+}
+
+// Pipeline returns a copy of the pipeline level state without its files.
+func (store *StateStore) Pipeline(pipelineName string) PipelineState {
+	if store == nil {
+		return PipelineState{}
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.refresh()
+
+	pipelineState := *store.pipelineState(pipelineName)
+	pipelineState.Files = nil
+	pipelineState.DeadLetters = nil
+	pipelineState.Folders = map[string]*FolderState{}
+	for folderPath, folderState := range store.pipelineState(pipelineName).Folders {
+		folderCopy := *folderState
+		pipelineState.Folders[folderPath] = &folderCopy
+	}
+	pipelineState.Receipts = append([]Receipt{}, pipelineState.Receipts...)
+	pipelineState.WriteBacks = append([]Receipt{}, pipelineState.WriteBacks...)
+
+	return pipelineState
+}
+
+// Files returns a copy of the state of every object of the pipeline.
+func (store *StateStore) Files(pipelineName string) []FileState {
+	if store == nil {
+		return nil
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.refresh()
+
+	files := []FileState{}
+	for _, fileState := range store.pipelineState(pipelineName).Files {
+		files = append(files, *fileState)
+	}
+
+	return files
+}
+
+func (store *StateStore) pipelineState(pipelineName string) *PipelineState {
+	pipelineState, exists := store.state.Pipelines[pipelineName]
+	if !exists {
+		pipelineState = &PipelineState{}
+		store.state.Pipelines[pipelineName] = pipelineState
+	}
+
+	if pipelineState.Files == nil {
+		pipelineState.Files = map[string]*FileState{}
+	}
+
+	if pipelineState.DeadLetters == nil {
+		pipelineState.DeadLetters = map[string]*DeadLetter{}
+	}
+
+	if pipelineState.Folders == nil {
+		pipelineState.Folders = map[string]*FolderState{}
+	}
+
+	return pipelineState
+}
+
+func (store *StateStore) save() error {
+	contents, err := json.MarshalIndent(store.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode state: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(store.path), 0700)
+	if err != nil {
+		return fmt.Errorf("unable to create state directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(store.path), filepath.Base(store.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to write state file %q: %w", store.path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(contents)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write state file %q: %w", store.path, err)
+	}
+
+	err = os.Rename(tmp.Name(), store.path)
+	if err != nil {
+		return fmt.Errorf("unable to write state file %q: %w", store.path, err)
+	}
+
+	info, err := os.Stat(store.path)
+	if err == nil {
+		store.modTime = info.ModTime()
+		store.size = info.Size()
+	}
+
+	return nil
+}
+
+// DeadLetters returns a copy of the dead-lettered objects of the pipeline.
+func (store *StateStore) DeadLetters(pipelineName string) []DeadLetter {
+	if store == nil {
+		return nil
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.refresh()
+
+	deadLetters := []DeadLetter{}
+	for _, deadLetter := range store.pipelineState(pipelineName).DeadLetters {
+		deadLetters = append(deadLetters, *deadLetter)
+	}
+	sort.Slice(deadLetters, func(i, j int) bool {
+		return deadLetters[i].Key < deadLetters[j].Key
+	})
+
+	return deadLetters
+}
+
+// PipelineNames returns the names of every pipeline with recorded state.
+func (store *StateStore) PipelineNames() []string {
+	if store == nil {
+		return nil
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.refresh()
+
+	names := []string{}
+	for name := range store.state.Pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
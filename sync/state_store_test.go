@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStateStoreBatchesSaves(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	state, err := NewStateStore(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.batchSaves(time.Hour)
+
+	uploaded := func(key string) {
+		t.Helper()
+		err := state.UpdateFile("site", key, func(fileState *FileState) {
+			fileState.markUploaded(time.Now())
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := func() map[string]*FileState {
+		t.Helper()
+		reopened, err := NewStateStore(statePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reopened.pipelineState("site").Files
+	}
+
+	// the first update is saved right away, later ones once the interval
+	// elapsed or on flush
+	uploaded("a.txt")
+	uploaded("b.txt")
+	if files := saved(); len(files) != 1 || files["a.txt"] == nil {
+		t.Fatalf("expected only a.txt to be saved, got %v", files)
+	}
+	if _, exists := state.File("site", "b.txt"); !exists {
+		t.Error("expected unsaved updates to be visible")
+	}
+
+	// another process changing the file in between keeps the batched updates
+	other, err := NewStateStore(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = other.UpdatePipeline("site", func(pipelineState *PipelineState) {
+		pipelineState.DeadLetters["c.txt"] = &DeadLetter{Key: "c.txt"}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := state.File("site", "b.txt"); !exists || len(state.DeadLetters("site")) != 1 {
+		t.Error("expected the batched updates to be replayed on the changed state")
+	}
+
+	err = state.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewStateStore(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened.Files("site")) != 2 || len(reopened.DeadLetters("site")) != 1 {
+		t.Errorf("expected both files and the dead letter to be saved, got %+v, %+v", reopened.Files("site"), reopened.DeadLetters("site"))
+	}
+}
@@ -0,0 +1,55 @@
+package sync
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/the-singularity-labs/cornelius/log"
+)
+
+// newStubServer serves handler for the duration of the test, standing in
+// for Vault, gateways, bundlers and S3 endpoints.
+func newStubServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newStubBucket connects to a bucket named "bucket" on a stub S3 endpoint
+// serving handler.
+func newStubBucket(t *testing.T, handler http.HandlerFunc) *ObjectStorageConnection {
+	t.Helper()
+
+	server := newStubServer(t, handler)
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	minioClient, err := minio.New(serverURL.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4("access", "secret", ""),
+		Region:       "us-east-1",
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &ObjectStorageConnection{
+		ctx:          context.Background(),
+		minioClient:  minioClient,
+		bucket:       "bucket",
+		isRecursive:  true,
+		contentTypes: map[string]cachedContentType{},
+		firstSeen:    map[string]firstSeen{},
+		logger:       log.NewTextLogger(slog.LevelError),
+	}
+}
@@ -0,0 +1,180 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	PipelineRunning    = "running"
+	PipelineRestarting = "restarting"
+	PipelineFailed     = "failed"
+	PipelineCompleted  = "completed"
+
+	DefaultMaxRestarts    = 5
+	DefaultRestartBackoff = Duration(10 * time.Second)
+	DefaultMaxBackoff     = Duration(10 * time.Minute)
+)
+
+// SupervisionConfig controls how failed pipelines are restarted. Pipelines
+// are restarted with exponential backoff up to MaxRestarts times in a row, -1
+// meaning without limit. The count is reset once a pipeline completes an
+// iteration successfully. The process only exits because of a failed pipeline when
+// ExitOnFailure is set, other pipelines keep running otherwise.
+type SupervisionConfig struct {
+	MaxRestarts   int      `yaml:"max_restarts"`
+	Backoff       Duration `yaml:"backoff"`
+	MaxBackoff    Duration `yaml:"max_backoff"`
+	ExitOnFailure bool     `yaml:"exit_on_failure"`
+}
+
+// PipelineHealth is the supervision status of a pipeline.
+type PipelineHealth struct {
+	Status    string    `json:"status"`
+	Restarts  int       `json:"restarts"`
+	LastError string    `json:"last_error,omitempty"`
+	Since     time.Time `json:"since"`
+}
+
+func (config SupervisionConfig) maxRestarts() int {
+	if config.MaxRestarts == 0 {
+		return DefaultMaxRestarts
+	}
+
+	return config.MaxRestarts
+}
+
+// restartBackoff is the jittered delay before the given restart of a pipeline.
+func (config SupervisionConfig) restartBackoff(restart int) time.Duration {
+	policy := retryPolicy{
+		initialBackoff: time.Duration(config.Backoff),
+		maxBackoff:     time.Duration(config.MaxBackoff),
+	}
+	if policy.initialBackoff == 0 {
+		policy.initialBackoff = time.Duration(DefaultRestartBackoff)
+	}
+	if policy.maxBackoff == 0 {
+		policy.maxBackoff = time.Duration(DefaultMaxBackoff)
+	}
+	policy.maxBackoff = max(policy.maxBackoff, policy.initialBackoff)
+
+	return policy.backoff(restart)
+}
+
+// pipelineFinished applies the restart policy to a pipeline that returned.
+// It returns an error when the process should exit.
+func (s *Synchronizer) pipelineFinished(ctx context.Context, running *runningPipeline) error {
+	name := running.pipeline.Name
+	if running.err == nil {
+		delete(s.pipelines, name)
+		s.setHealth(name, PipelineCompleted, running.restarts, nil)
+		return nil
+	}
+
+	if running.iterated.Load() {
+		running.restarts = 0
+	}
+
+	maxRestarts := s.config.Supervision.maxRestarts()
+	if maxRestarts < 0 || running.restarts < maxRestarts {
+		backoff := s.config.Supervision.restartBackoff(running.restarts + 1)
+		s.logger.Error("pipeline failed, restarting", "pipeline", name, "restarts", running.restarts, "backoff", backoff, "error", running.err)
+		s.setHealth(name, PipelineRestarting, running.restarts, running.err)
+
+		go func() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			select {
+			case s.restarts <- running:
+			case <-ctx.Done():
+			}
+		}()
+		return nil
+	}
+
+	delete(s.pipelines, name)
+	s.logger.Error("pipeline failed, giving up", "pipeline", name, "restarts", running.restarts, "error", running.err)
+	s.setHealth(name, PipelineFailed, running.restarts, running.err)
+
+	if s.config.Supervision.ExitOnFailure {
+		return fmt.Errorf("pipeline %q failed after %d restarts: %w", name, running.restarts, running.err)
+	}
+
+	return nil
+}
+
+// failedPipelines returns an error naming every pipeline that gave up.
+func (s *Synchronizer) failedPipelines() error {
+	s.healthMutex.Lock()
+	defer s.healthMutex.Unlock()
+
+	failed := []string{}
+	for name, health := range s.health {
+		if health.Status == PipelineFailed {
+			failed = append(failed, name)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	sort.Strings(failed)
+
+	return fmt.Errorf("pipelines failed: %s", strings.Join(failed, ", "))
+}
+
+func (s *Synchronizer) setHealth(name, status string, restarts int, err error) {
+	health := PipelineHealth{
+		Status:   status,
+		Restarts: restarts,
+		Since:    time.Now(),
+	}
+	if err != nil {
+		health.LastError = err.Error()
+	}
+
+	s.healthMutex.Lock()
+	s.health[name] = health
+	s.healthMutex.Unlock()
+
+	up := 0.0
+	if status == PipelineRunning {
+		up = 1
+	}
+	labels := map[string]string{"pipeline": name}
+	s.metrics.SetGauge("cornelius_pipeline_up", "Whether a pipeline is running.", labels, up)
+	s.metrics.SetGauge("cornelius_pipeline_restarts", "Number of times a pipeline was restarted after failing.", labels, float64(restarts))
+}
+
+func (s *Synchronizer) deleteHealth(name string) {
+	s.healthMutex.Lock()
+	delete(s.health, name)
+	s.healthMutex.Unlock()
+}
+
+// serveHealth reports the health of every pipeline, answering 503 while any
+// pipeline is failed or restarting.
+func (s *Synchronizer) serveHealth(w http.ResponseWriter, r *http.Request) {
+	s.healthMutex.Lock()
+	defer s.healthMutex.Unlock()
+
+	status := http.StatusOK
+	for _, health := range s.health {
+		if health.Status == PipelineFailed || health.Status == PipelineRestarting {
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"pipelines": s.health})
+}
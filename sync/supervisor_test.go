@@ -0,0 +1,123 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/the-singularity-labs/cornelius/log"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	config := SupervisionConfig{}
+	for restart, expected := range map[int]time.Duration{
+		1: time.Duration(DefaultRestartBackoff),
+		2: 2 * time.Duration(DefaultRestartBackoff),
+		3: 4 * time.Duration(DefaultRestartBackoff),
+		8: time.Duration(DefaultMaxBackoff),
+	} {
+		backoff := config.restartBackoff(restart)
+		if backoff < expected/2 || backoff > expected {
+			t.Errorf("expected restart %d to back off between %s and %s, got %s", restart, expected/2, expected, backoff)
+		}
+	}
+
+	// a max_backoff below the backoff does not shorten the first restart
+	config = SupervisionConfig{Backoff: Duration(time.Hour), MaxBackoff: Duration(time.Minute)}
+	if backoff := config.restartBackoff(3); backoff < 30*time.Minute || backoff > time.Hour {
+		t.Errorf("expected the backoff to be capped at an hour, got %s", backoff)
+	}
+}
+
+func newSupervisedSynchronizer(supervision SupervisionConfig) *Synchronizer {
+	return &Synchronizer{
+		config:    Config{Supervision: supervision},
+		metrics:   NewMetrics(),
+		logger:    log.NewTextLogger(slog.LevelError),
+		pipelines: map[string]*runningPipeline{},
+		restarts:  make(chan *runningPipeline),
+		health:    map[string]PipelineHealth{},
+	}
+}
+
+func TestPipelineFinished(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := newSupervisedSynchronizer(SupervisionConfig{MaxRestarts: 2, Backoff: Duration(time.Millisecond)})
+	healthStatus := func() int {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		s.serveHealth(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+		return recorder.Code
+	}
+	finished := func(running *runningPipeline) {
+		t.Helper()
+		s.pipelines[running.pipeline.Name] = running
+		err := s.pipelineFinished(ctx, running)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failed := &runningPipeline{pipeline: Pipeline{Name: "site"}, restarts: 1, err: errors.New("gateway unreachable")}
+	finished(failed)
+	if health := s.health["site"]; health.Status != PipelineRestarting || health.LastError != "gateway unreachable" {
+		t.Errorf("expected the pipeline to restart, got %+v", health)
+	}
+	if status := healthStatus(); status != http.StatusServiceUnavailable {
+		t.Errorf("expected /health to answer 503 while restarting, got %d", status)
+	}
+	select {
+	case restarted := <-s.restarts:
+		if restarted != failed {
+			t.Error("expected the failed pipeline to be restarted")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the pipeline to be restarted after the backoff")
+	}
+
+	// a pipeline that iterated successfully before failing starts counting
+	// its restarts over
+	iterated := &runningPipeline{pipeline: Pipeline{Name: "site"}, restarts: 2, err: errors.New("gateway unreachable")}
+	iterated.iterated.Store(true)
+	finished(iterated)
+	if iterated.restarts != 0 || s.health["site"].Status != PipelineRestarting {
+		t.Errorf("expected the restart count to be reset, got %d restarts, %+v", iterated.restarts, s.health["site"])
+	}
+	<-s.restarts
+
+	// without a successful iteration the pipeline gives up after max_restarts
+	finished(&runningPipeline{pipeline: Pipeline{Name: "site"}, restarts: 2, err: errors.New("gateway unreachable")})
+	if _, exists := s.pipelines["site"]; exists || s.health["site"].Status != PipelineFailed {
+		t.Errorf("expected the pipeline to give up, got %+v", s.health["site"])
+	}
+	if status := healthStatus(); status != http.StatusServiceUnavailable {
+		t.Errorf("expected /health to answer 503 for a failed pipeline, got %d", status)
+	}
+	if err := s.failedPipelines(); err == nil {
+		t.Error("expected the failed pipeline to be reported")
+	}
+
+	finished(&runningPipeline{pipeline: Pipeline{Name: "site"}})
+	if s.health["site"].Status != PipelineCompleted || healthStatus() != http.StatusOK {
+		t.Errorf("expected a completed pipeline to be healthy, got %+v", s.health["site"])
+	}
+}
+
+func TestPipelineFinishedExitOnFailure(t *testing.T) {
+	for _, exitOnFailure := range []bool{false, true} {
+		s := newSupervisedSynchronizer(SupervisionConfig{MaxRestarts: 1, ExitOnFailure: exitOnFailure})
+		running := &runningPipeline{pipeline: Pipeline{Name: "site"}, restarts: 1, err: errors.New("invalid drive id")}
+		s.pipelines["site"] = running
+
+		err := s.pipelineFinished(context.Background(), running)
+		if (err != nil) != exitOnFailure {
+			t.Errorf("expected the process to exit only with exit_on_failure set, got %v with exit_on_failure %v", err, exitOnFailure)
+		}
+	}
+}
@@ -3,54 +3,325 @@ package sync
 import (
 	"context"
 	"fmt"
+	"math/big"
+	"net/http"
 	"os"
-	"path/filepath"
+	"path"
 	"strings"
+	gosync "sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/the-singularity-labs/cornelius/arweave"
 	"github.com/the-singularity-labs/cornelius/log"
-
-	"golang.org/x/sync/errgroup"
 )
 
 type Synchronizer struct {
-	ardrivecliPath string
-	config         Config
-	logger         log.Logger
+	ardrivecliPath  string
+	configPath      string
+	watchConfigFile bool
+	config          Config
+	secrets         *SecretResolver
+	state           *StateStore
+	metrics         *Metrics
+	logger          log.Logger
+	pipelines       map[string]*runningPipeline
+	results         chan *runningPipeline
+	restarts        chan *runningPipeline
+	health          map[string]PipelineHealth
+	healthMutex     gosync.Mutex
+}
+
+type runningPipeline struct {
+	pipeline Pipeline
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopped  bool
+	restarts int
+	iterated atomic.Bool
+	err      error
 }
 
-func New(logger log.Logger, ardrivecliPath string, config Config) *Synchronizer {
+func New(logger log.Logger, ardrivecliPath string, config Config) (*Synchronizer, error) {
+	secrets, err := NewSecretResolver(config.Secrets)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize secret providers: %w", err)
+	}
+
+	state, err := NewStateStore(config.StatePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize state store: %w", err)
+	}
+	state.batchSaves(config.stateSaveInterval())
+
 	return &Synchronizer{
 		logger:         logger,
 		ardrivecliPath: ardrivecliPath,
 		config:         config,
-	}
+		secrets:        secrets,
+		state:          state,
+		metrics:        NewMetrics(),
+		pipelines:      map[string]*runningPipeline{},
+		results:        make(chan *runningPipeline),
+		restarts:       make(chan *runningPipeline),
+		health:         map[string]PipelineHealth{},
+	}, nil
+}
+
+// EnableReload reloads the configuration from configPath on SIGHUP and, when
+// watchFile is set, whenever the file changes.
+func (s *Synchronizer) EnableReload(configPath string, watchFile bool) {
+	s.configPath = configPath
+	s.watchConfigFile = watchFile
 }
 
 func (s *Synchronizer) Start(ctx context.Context) error {
-	var g errgroup.Group
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if s.config.Metrics.Address != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", s.metrics)
+		mux.HandleFunc("/health", s.serveHealth)
+		s.serveMetrics(ctx, mux)
+	}
 
 	s.logger.Info("initializing pipelines", "count", len(s.config.Pipelines))
 	for _, pipeline := range s.config.Pipelines {
-		g.Go(func() error {
-			return s.handlePipeline(ctx, pipeline)
-		})
+		s.startPipeline(ctx, pipeline, 0)
 	}
 
 	s.logger.Info("all pipelines initialized")
 
-	return g.Wait()
+	reloads, err := s.reloadSignals(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to watch configuration: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.stopAllPipelines()
+			return nil
+		case <-reloads:
+			s.reload(ctx)
+		case running := <-s.results:
+			if running.stopped || s.pipelines[running.pipeline.Name] != running {
+				continue
+			}
+
+			err := s.pipelineFinished(ctx, running)
+			if err != nil {
+				s.stopAllPipelines()
+				return err
+			}
+
+			if len(s.pipelines) == 0 && !s.watchConfigFile {
+				return s.failedPipelines()
+			}
+		case running := <-s.restarts:
+			if running.stopped || s.pipelines[running.pipeline.Name] != running {
+				continue
+			}
+
+			s.logger.Info("restarting pipeline", "pipeline", running.pipeline.Name, "restart", running.restarts+1)
+			s.startPipeline(ctx, running.pipeline, running.restarts+1)
+		}
+	}
+}
+
+func (s *Synchronizer) startPipeline(ctx context.Context, pipeline Pipeline, restarts int) {
+	pipelineCtx, cancel := context.WithCancel(ctx)
+	running := &runningPipeline{
+		pipeline: pipeline,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		restarts: restarts,
+	}
+	s.pipelines[pipeline.Name] = running
+	s.setHealth(pipeline.Name, PipelineRunning, restarts, nil)
+
+	// a successful iteration proves a restart worked, the restart count
+	// only limits consecutive failures
+	iterated := func() {
+		if running.iterated.CompareAndSwap(false, true) && restarts > 0 {
+			s.setHealth(pipeline.Name, PipelineRunning, 0, nil)
+		}
+	}
+
+	// the pipeline reads the configuration it was started with, reloads
+	// replace s.config while it runs
+	config := s.config
+	go func() {
+		running.err = s.handlePipeline(pipelineCtx, config, pipeline, iterated)
+		cancel()
+		close(running.done)
+
+		select {
+		case s.results <- running:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// stopPipeline cancels the pipeline and waits for its in-flight upload to finish.
+func (s *Synchronizer) stopPipeline(name string) {
+	running, exists := s.pipelines[name]
+	if !exists {
+		return
+	}
+
+	s.logger.Info("stopping pipeline", "pipeline", name)
+	running.stopped = true
+	running.cancel()
+	<-running.done
+	delete(s.pipelines, name)
+	s.deleteHealth(name)
+	s.metrics.DeleteGauges("pipeline", name)
+}
+
+func (s *Synchronizer) stopAllPipelines() {
+	for name := range s.pipelines {
+		s.stopPipeline(name)
+	}
 }
 
-func (s *Synchronizer) handlePipeline(ctx context.Context, pipeline Pipeline) error {
+func (s *Synchronizer) handlePipeline(ctx context.Context, config Config, pipeline Pipeline, iterated func()) error {
 	logger := s.logger.With("pipeline", pipeline.Name)
 
-	objConn, err := NewObjectStorageConnection(ctx, logger, s.config.TmpDirectory, pipeline.Bucket.Host, pipeline.Bucket.Name, pipeline.Bucket.Prefix, pipeline.Bucket.AccessId, pipeline.Bucket.SecretKey, pipeline.Bucket.IsSecure, pipeline.Bucket.IsRecursive)
+	accessId, err := s.secrets.Resolve(ctx, pipeline.Bucket.AccessId)
+	if err != nil {
+		return fmt.Errorf("unable to resolve bucket access id for pipeline %q: %w", pipeline.Name, err)
+	}
+
+	secretKey, err := s.secrets.Resolve(ctx, pipeline.Bucket.SecretKey)
+	if err != nil {
+		return fmt.Errorf("unable to resolve bucket secret key for pipeline %q: %w", pipeline.Name, err)
+	}
+
+	walletPath, removeWallet, err := s.secrets.ResolveFile(ctx, pipeline.DestinationDrive.WalletPath)
+	if err != nil {
+		return fmt.Errorf("unable to resolve wallet for pipeline %q: %w", pipeline.Name, err)
+	}
+	defer removeWallet()
+
+	walletPassword, err := s.secrets.Resolve(ctx, pipeline.DestinationDrive.Password)
+	if err != nil {
+		return fmt.Errorf("unable to resolve drive password for pipeline %q: %w", pipeline.Name, err)
+	}
+
+	mimetypes, err := NewMimetypeDetector(pipeline.ContentTypes)
+	if err != nil {
+		return fmt.Errorf("invalid content types for pipeline %q: %w", pipeline.Name, err)
+	}
+
+	objConn, err := NewObjectStorageConnection(ctx, logger, config.TmpDirectory, pipeline.Bucket, accessId, secretKey, pipeline.Filters, mimetypes)
 	if err != nil {
 		return fmt.Errorf("unable to initialize object storage connection %q: %w", pipeline.Name, err)
 	}
 
-	ardriveClient, err := NewArdriveClient(logger, s.ardrivecliPath, pipeline.DestinationDrive.WalletPath, pipeline.DestinationDrive.Password, pipeline.DestinationDrive.Id, pipeline.DestinationDrive.ParentFolderId, pipeline.DestinationDrive.IsPublic, pipeline.EnableManifest)
+	if pipeline.Receipts != nil {
+		err = pipeline.Receipts.validate()
+		if err != nil {
+			return fmt.Errorf("invalid receipt settings for pipeline %q: %w", pipeline.Name, err)
+		}
+		objConn.ExcludePrefix(pipeline.receiptsPrefix())
+	}
+
+	if pipeline.WriteBack != nil {
+		err = pipeline.WriteBack.validate()
+		if err != nil {
+			return fmt.Errorf("invalid write back settings for pipeline %q: %w", pipeline.Name, err)
+		}
+		if pipeline.WriteBack.Mode == WriteBackSidecar {
+			objConn.ExcludeSuffix(SidecarSuffix)
+		}
+	}
+
+	gateway, err := config.gatewayConfig(pipeline)
+	if err != nil {
+		return fmt.Errorf("invalid gateway for pipeline %q: %w", pipeline.Name, err)
+	}
+
+	uploadStrategy, err := pipeline.Upload.strategy()
+	if err != nil {
+		return fmt.Errorf("invalid upload settings for pipeline %q: %w", pipeline.Name, err)
+	}
+
+	var turboClient *TurboClient
+	if uploadStrategy == UploadStrategyTurbo {
+		turboClient, err = NewTurboClient(pipeline.Upload, walletPath)
+		if err != nil {
+			return fmt.Errorf("unable to initialize turbo client for pipeline %q: %w", pipeline.Name, err)
+		}
+	}
+
+	wallet, err := arweave.LoadWallet(walletPath)
+	if err != nil {
+		return fmt.Errorf("unable to load wallet for pipeline %q: %w", pipeline.Name, err)
+	}
+
+	lowBalance := new(big.Int)
+	if pipeline.DestinationDrive.LowBalance != "" {
+		lowBalance, err = parseTokenAmount(pipeline.DestinationDrive.LowBalance)
+		if err != nil {
+			return fmt.Errorf("invalid low_balance for pipeline %q: %w", pipeline.Name, err)
+		}
+	}
+
+	arweaveGateway := arweave.NewGateway(gateway.URL, gateway.UploadURL, gateway.GraphqlURL, gateway.requestTimeout(), time.Duration(gateway.UploadTimeout))
+
+	var bulk *bulkUploader
+	if pipeline.Upload.Bulk.Enabled {
+		if uploadStrategy != UploadStrategyL1 {
+			return fmt.Errorf("bulk uploads of pipeline %q require the l1 upload strategy", pipeline.Name)
+		} else if !pipeline.DestinationDrive.IsPublic {
+			return fmt.Errorf("bulk uploads of pipeline %q are only supported for public drives", pipeline.Name)
+		}
+
+		bulk, err = newBulkUploader(pipeline.Upload.Bulk, wallet, arweaveGateway, pipeline.DestinationDrive.Id, pipeline.DestinationDrive.ParentFolderId)
+		if err != nil {
+			return fmt.Errorf("invalid bulk settings for pipeline %q: %w", pipeline.Name, err)
+		}
+	}
+
+	var confirmations *confirmationTracker
+	if pipeline.Confirmation != nil && pipeline.Confirmation.Enabled {
+		if s.state == nil {
+			return fmt.Errorf("confirmation tracking of pipeline %q requires state_path to be set", pipeline.Name)
+		}
+
+		confirmations, err = newConfirmationTracker(*pipeline.Confirmation)
+		if err != nil {
+			return fmt.Errorf("invalid confirmation settings for pipeline %q: %w", pipeline.Name, err)
+		}
+	}
+
+	if pipeline.Verify && s.state == nil {
+		return fmt.Errorf("verification of pipeline %q requires state_path to be set", pipeline.Name)
+	}
+
+	if pipeline.manifestConfig().Enabled && !pipeline.DestinationDrive.IsPublic {
+		return fmt.Errorf("manifest of pipeline %q requires a public drive, gateways cannot resolve encrypted files", pipeline.Name)
+	}
+
+	if pipeline.IpfsTag && !pipeline.DestinationDrive.IsPublic {
+		return fmt.Errorf("ipfs_tag of pipeline %q requires a public drive, the CID would disclose the content of encrypted data", pipeline.Name)
+	}
+
+	if pipeline.Verify && !pipeline.DestinationDrive.IsPublic {
+		return fmt.Errorf("verification of pipeline %q requires a public drive, private drives store encrypted data", pipeline.Name)
+	}
+
+	retry, err := newRetryPolicy(pipeline.Retry)
+	if err != nil {
+		return fmt.Errorf("invalid retry settings for pipeline %q: %w", pipeline.Name, err)
+	}
+	if retry.deadLetter && s.state == nil {
+		return fmt.Errorf("dead letters of pipeline %q require state_path to be set", pipeline.Name)
+	}
+
+	ardriveClient, err := NewArdriveClient(logger, s.ardrivecliPath, gateway, pipeline.Upload, walletPath, walletPassword, pipeline.DestinationDrive.Id, pipeline.DestinationDrive.ParentFolderId, pipeline.DestinationDrive.IsPublic)
 	if err != nil {
 		return fmt.Errorf("unable to initialize ardrive client %q: %w", pipeline.Name, err)
 	}
@@ -69,83 +340,114 @@ func (s *Synchronizer) handlePipeline(ctx context.Context, pipeline Pipeline) er
 		return fmt.Errorf("ardrive folder with id %q does not exist for pipeline %q", pipeline.DestinationDrive.ParentFolderId, pipeline.Name)
 	}
 
-	repeatOnSetFrequency := true
-	sleepDuration := time.Duration(pipeline.Frequency)
-	if sleepDuration == time.Duration(0) {
-		logger.Info("no frequency set, proces will exit after first iteration.")
-		repeatOnSetFrequency = false
+	maxFileSize, onOversize, err := sizeLimitPolicy(pipeline)
+	if err != nil {
+		return fmt.Errorf("invalid size limit for pipeline %q: %w", pipeline.Name, err)
 	}
 
-	logger.Info("starting sync")
-	for {
-		logger.Info("getting existing files")
-		objectStorageFiles, err := objConn.ListFiles()
-		if err != nil {
-			return fmt.Errorf("unable to get files to sync: %w", err)
-		}
+	err = validateMetadataMapping(pipeline.Metadata)
+	if err != nil {
+		return fmt.Errorf("invalid metadata mapping for pipeline %q: %w", pipeline.Name, err)
+	}
 
-		logger.Info("acquired object storage files", "count", len(objectStorageFiles))
+	tags, err := NewTransactionTags(pipeline.Tags)
+	if err != nil {
+		return fmt.Errorf("invalid tags for pipeline %q: %w", pipeline.Name, err)
+	}
 
-		ardriveFiles, err := ardriveClient.ListFiles()
-		if err != nil {
-			return fmt.Errorf("unable to get drives to sync: %w", err)
+	var arnsClient *ArnsClient
+	if pipeline.Arns != nil {
+		if !pipeline.manifestConfig().Enabled {
+			return fmt.Errorf("arns for pipeline %q requires the manifest to be enabled", pipeline.Name)
 		}
 
-		logger.Info("acquired ardrive files", "count", len(ardriveFiles))
-
-		deltaObjectStorageFiles, err := identifyNetNewFiles(objectStorageFiles, ardriveFiles, parentPath)
+		arnsClient, err = NewArnsClient(*pipeline.Arns, walletPath)
 		if err != nil {
-			return fmt.Errorf("unable to compare object storage files to ardrive files: %w", err)
+			return fmt.Errorf("unable to initialize arns client for pipeline %q: %w", pipeline.Name, err)
 		}
-		logger.Info("idenitifed files to sync", "count", len(deltaObjectStorageFiles))
-
-		for _, objectStorageFileToSync := range deltaObjectStorageFiles {
-			err := func() error {
-				logger := logger.With("object", objectStorageFileToSync.Key)
-				logger.Debug("downloading file from object storage")
-				localFile, err := objConn.DownloadFile(objectStorageFileToSync)
-				if err != nil {
-					return fmt.Errorf("unable to download object %q in order to reupload to arweave: %w", objectStorageFileToSync.Key, err)
-				}
-
-				defer func() {
-					logger.Debug("removing staged file")
-					removeLocalFile(localFile)
-				}()
+	}
 
-				logger.Debug("finished dowloading file from object storage", "path", localFile.Path)
+	run := &pipelineRun{
+		pipeline:      pipeline,
+		logger:        logger,
+		objConn:       objConn,
+		ardriveClient: ardriveClient,
+		parentPath:    parentPath,
+		maxFileSize:   maxFileSize,
+		onOversize:    onOversize,
+		tags:          tags,
+		state:         s.state,
+		mimetypes:     mimetypes,
+		manifest:      pipeline.manifestConfig(),
+		arns:          arnsClient,
+		turbo:         turboClient,
+		bulk:          bulk,
+		confirmations: confirmations,
+		gateway:       arweaveGateway,
+		gatewayURL:    gateway.URL,
+		metrics:       s.metrics,
+		walletAddress: wallet.Address(),
+		lowBalance:    lowBalance,
+		retry:         retry,
+		tmpDirectory:  config.TmpDirectory,
+	}
 
-				txData, err := ardriveClient.upsertFile(localFile) // TODO: compile response statistics intometrics
-				if err != nil {
-					return fmt.Errorf("unable to upsert %q to arweave: %w", localFile.Dir, err)
-				}
+	if bulk != nil {
+		run.restorePending()
+	}
+	if pipeline.Receipts != nil {
+		run.restoreReceipts()
+	}
+	if pipeline.WriteBack != nil {
+		run.restoreWriteBacks()
+	}
 
-				totalFees, err := txData.TotalFees()
-				if err != nil {
-					return fmt.Errorf("unable to upsert %q to arweave: %w", strings.Join(txData.EntityIds(), ", "), err)
-				}
+	repeatOnSetFrequency := true
+	sleepDuration := time.Duration(pipeline.Frequency)
+	if sleepDuration == time.Duration(0) {
+		logger.Info("no frequency set, proces will exit after first iteration.")
+		repeatOnSetFrequency = false
+	}
 
-				logger.Info("file uploaded to arweave", "fees_paid", totalFees)
+	_, err = run.walletBalance(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to check wallet of pipeline %q: %w", pipeline.Name, err)
+	}
 
-				return nil
-			}()
-			if err != nil {
-				return err
+	logger.Info("starting sync")
+	failedIterations := 0
+	for {
+		err := run.iterate(ctx)
+		if err != nil && (!repeatOnSetFrequency || classifyError(err) != ErrorTransient) {
+			return err
+		} else if err != nil {
+			failedIterations++
+			if failedIterations >= run.retry.iterationFailureThreshold {
+				return &giveUpError{reason: fmt.Sprintf("giving up after %d consecutive failed iterations", failedIterations), err: err}
 			}
+			logger.Warn("iteration failed with transient error, retrying next iteration", "consecutive_failures", failedIterations, "error", err)
+		} else {
+			failedIterations = 0
+			iterated()
 		}
 
-		if !repeatOnSetFrequency {
+		if !repeatOnSetFrequency || ctx.Err() != nil {
 			break
 		}
 
 		logger.Info("sleeping inside pipeline", "duration", sleepDuration)
-		time.Sleep(sleepDuration)
+		select {
+		case <-ctx.Done():
+			logger.Info("pipeline stopped")
+			return nil
+		case <-time.After(sleepDuration):
+		}
 	}
 
 	return nil
 }
 
-func identifyNetNewFiles(objectStorageFiles ObjectStorageFiles, ardriveFiles ArdriveFiles, parentPath string) (ObjectStorageFiles, error) {
+func identifyNetNewFiles(objectStorageFiles ObjectStorageFiles, ardriveFiles ArdriveFiles, parentPath string, splitSize int64) (ObjectStorageFiles, error) {
 	filtered := ObjectStorageFiles{}
 
 	objectStorageFileMap := map[string]ObjectStorageFile{}
@@ -158,8 +460,8 @@ func identifyNetNewFiles(objectStorageFiles ObjectStorageFiles, ardriveFiles Ard
 		ardriveFileMap[ardriveFile.Path] = ardriveFile
 	}
 
-	for key, objectStorageFile := range objectStorageFileMap {
-		potentialPath := filepath.Join(parentPath, key)
+	for _, objectStorageFile := range objectStorageFileMap {
+		potentialPath := ardrivePathForObject(parentPath, objectStorageFile, splitSize)
 
 		if ardriveFile, exists := ardriveFileMap[potentialPath]; !exists || objectStorageFile.LastModified.After(ardriveFile.LastModified) {
 			filtered = append(filtered, objectStorageFile)
@@ -169,6 +471,18 @@ func identifyNetNewFiles(objectStorageFiles ObjectStorageFiles, ardriveFiles Ard
 	return filtered, nil
 }
 
+// keyWithinParent reports whether the drive path of key stays below the
+// parent folder. Keys such as "a/../../b" are valid object keys but would
+// be staged and uploaded outside of it.
+func keyWithinParent(parentPath, key string) bool {
+	return strings.HasPrefix(path.Join(parentPath, key), path.Clean(parentPath)+"/")
+}
+
 func removeLocalFile(localFile LocalFile) {
+	if localFile.Path == "" {
+		return
+	}
+
 	os.Remove(localFile.Path)
+	os.RemoveAll(splitPartsKey(localFile.Path))
 }
@@ -0,0 +1,46 @@
+package sync
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdentifyNetNewFilesSplitObjects(t *testing.T) {
+	lastModified := time.Now().Add(-time.Hour)
+	objectStorageFiles := ObjectStorageFiles{
+		{Key: "a/backup.tar", Size: 300, LastModified: lastModified},
+		{Key: "b/backup.tar", Size: 300, LastModified: lastModified},
+		{Key: "backup.tar", Size: 300, LastModified: lastModified},
+		{Key: "b/notes.txt", Size: 10, LastModified: lastModified},
+	}
+
+	// only the parts of a/backup.tar were uploaded so far
+	ardriveFiles := ArdriveFiles{
+		{Path: "/drive/a%2Fbackup.tar.parts/backup.tar.part0001", LastModified: time.Now()},
+		{Path: "/drive/a%2Fbackup.tar.parts/backup.tar.part0002", LastModified: time.Now()},
+		{Path: "/drive/b/notes.txt", LastModified: time.Now()},
+	}
+
+	delta, err := identifyNetNewFiles(objectStorageFiles, ardriveFiles, "/drive", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{}
+	for _, objectStorageFile := range delta {
+		keys = append(keys, objectStorageFile.Key)
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "b/backup.tar,backup.tar" {
+		t.Errorf("expected b/backup.tar and backup.tar to be uploaded, got %v", keys)
+	}
+
+	if path := ardrivePathForObject("/drive", objectStorageFiles[2], 100); path != "/drive/backup.tar.parts/backup.tar.part0001" {
+		t.Errorf("expected the parts folder of a key without prefix to keep its name, got %s", path)
+	}
+	if splitPartsKey("a/100%/x") != "a%2F100%25%2Fx.parts" {
+		t.Errorf("expected slashes and percent signs to be escaped, got %s", splitPartsKey("a/100%/x"))
+	}
+}
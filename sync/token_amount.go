@@ -0,0 +1,30 @@
+package sync
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Both AR and Turbo credits divide into 10^12 winston respectively winc.
+const winstonPerToken = 1_000_000_000_000
+
+// parseTokenAmount parses a decimal amount of AR or credits into winston.
+func parseTokenAmount(amount string) (*big.Int, error) {
+	tokens, ok := new(big.Rat).SetString(amount)
+	if !ok || tokens.Sign() < 0 {
+		return nil, fmt.Errorf("%q is not a valid amount", amount)
+	}
+	tokens.Mul(tokens, new(big.Rat).SetInt64(winstonPerToken))
+
+	return new(big.Int).Quo(tokens.Num(), tokens.Denom()), nil
+}
+
+// formatTokenAmount renders an amount of winston as AR or credits.
+func formatTokenAmount(winston *big.Int) string {
+	return new(big.Rat).SetFrac(winston, big.NewInt(winstonPerToken)).FloatString(6)
+}
+
+func tokenAmountFloat(winston *big.Int) float64 {
+	tokens, _ := new(big.Rat).SetFrac(winston, big.NewInt(winstonPerToken)).Float64()
+	return tokens
+}
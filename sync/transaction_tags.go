@@ -0,0 +1,68 @@
+package sync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"text/template"
+)
+
+// TransactionTagData is available to the templates of pipeline tags, e.g. "{{.Key}}".
+type TransactionTagData struct {
+	Key          string
+	Bucket       string
+	PipelineName string
+	DriveId      string
+	SHA256       string
+	Size         int64
+	ContentType  string
+}
+
+type TransactionTags map[string]*template.Template
+
+func NewTransactionTags(tags map[string]string) (TransactionTags, error) {
+	templates := TransactionTags{}
+	for name, value := range tags {
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse template of tag %q: %w", name, err)
+		}
+		templates[name] = tmpl
+	}
+
+	return templates, nil
+}
+
+// Render executes every tag template against the file being uploaded.
+func (tags TransactionTags) Render(data TransactionTagData) (map[string]string, error) {
+	rendered := map[string]string{}
+	for name, tmpl := range tags {
+		var value bytes.Buffer
+		err := tmpl.Execute(&value, data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to render tag %q: %w", name, err)
+		}
+		rendered[name] = value.String()
+	}
+
+	return rendered, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to open %q: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("unable to hash %q: %w", path, err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
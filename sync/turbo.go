@@ -0,0 +1,135 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/the-singularity-labs/cornelius/arweave"
+)
+
+const (
+	UploadStrategyL1    = "l1"
+	UploadStrategyTurbo = "turbo"
+
+	DefaultTurboPaymentURL = "https://payment.ardrive.io"
+)
+
+// UploadConfig selects how files are uploaded: as L1 transactions paid in AR
+// or as ANS-104 data items through a Turbo compatible bundler paid in credits.
+type UploadConfig struct {
+	Strategy   string     `yaml:"strategy"`
+	TurboURL   string     `yaml:"turbo_url"`
+	PaymentURL string     `yaml:"payment_url"`
+	LowBalance string     `yaml:"low_balance"`
+	Timeout    Duration   `yaml:"timeout"`
+	Bulk       BulkConfig `yaml:"bulk"`
+}
+
+// TurboClient queries the credit balance of a wallet and upload prices from
+// a Turbo payment service.
+type TurboClient struct {
+	httpClient *http.Client
+	paymentURL string
+	address    string
+	lowBalance *big.Int
+}
+
+func (config UploadConfig) strategy() (string, error) {
+	switch config.Strategy {
+	case "", UploadStrategyL1:
+		return UploadStrategyL1, nil
+	case UploadStrategyTurbo:
+		return UploadStrategyTurbo, nil
+	}
+
+	return "", fmt.Errorf("%q is not a valid upload strategy", config.Strategy)
+}
+
+func NewTurboClient(config UploadConfig, walletPath string) (*TurboClient, error) {
+	paymentURL := config.PaymentURL
+	if paymentURL == "" {
+		paymentURL = DefaultTurboPaymentURL
+	}
+
+	lowBalance := new(big.Int)
+	if config.LowBalance != "" {
+		var err error
+		lowBalance, err = parseTokenAmount(config.LowBalance)
+		if err != nil {
+			return nil, fmt.Errorf("invalid low_balance: %w", err)
+		}
+	}
+
+	timeout := time.Duration(config.Timeout)
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	wallet, err := arweave.LoadWallet(walletPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TurboClient{
+		httpClient: &http.Client{Timeout: timeout},
+		paymentURL: strings.TrimSuffix(paymentURL, "/"),
+		address:    wallet.Address(),
+		lowBalance: lowBalance,
+	}, nil
+}
+
+// Balance returns the credit balance of the wallet in winc.
+func (client *TurboClient) Balance(ctx context.Context) (*big.Int, error) {
+	// the payment service does not know wallets that never topped up
+	return client.getWinc(ctx, fmt.Sprintf("%s/v1/account/balance/arweave?address=%s", client.paymentURL, client.address), true)
+}
+
+// Price returns the price of uploading the given number of bytes in winc.
+func (client *TurboClient) Price(ctx context.Context, bytes int64) (*big.Int, error) {
+	return client.getWinc(ctx, fmt.Sprintf("%s/v1/price/bytes/%d", client.paymentURL, bytes), false)
+}
+
+func (client *TurboClient) getWinc(ctx context.Context, url string, notFoundIsZero bool) (*big.Int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build turbo request: %w", err)
+	}
+
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach turbo payment service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read turbo response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound && notFoundIsZero {
+		return new(big.Int), nil
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("turbo payment service returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	result := struct {
+		Winc string `json:"winc"`
+	}{}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse turbo response: %w", err)
+	}
+
+	winc, ok := new(big.Int).SetString(result.Winc, 10)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a valid winc amount", result.Winc)
+	}
+
+	return winc, nil
+}
@@ -0,0 +1,142 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/the-singularity-labs/cornelius/arweave/arweavetest"
+	"github.com/the-singularity-labs/cornelius/log"
+)
+
+// newTurboStub serves the balance of funded addresses and a price of one winc
+// per byte like the Turbo payment service.
+func newTurboStub(t *testing.T, balances map[string]string) *httptest.Server {
+	t.Helper()
+
+	return newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/account/balance/arweave":
+			balance, exists := balances[r.URL.Query().Get("address")]
+			if !exists {
+				http.Error(w, "User Not Found", http.StatusNotFound)
+				return
+			}
+			fmt.Fprintf(w, `{"winc": %q, "controlledWinc": %q}`, balance, balance)
+		case strings.HasPrefix(r.URL.Path, "/v1/price/bytes/"):
+			fmt.Fprintf(w, `{"winc": %q, "adjustments": []}`, strings.TrimPrefix(r.URL.Path, "/v1/price/bytes/"))
+		default:
+			http.Error(w, "Not Found", http.StatusNotFound)
+		}
+	})
+}
+
+func newTestTurboClient(t *testing.T, server *httptest.Server, lowBalance string) *TurboClient {
+	t.Helper()
+
+	client, err := NewTurboClient(UploadConfig{Strategy: UploadStrategyTurbo, PaymentURL: server.URL + "/", LowBalance: lowBalance}, arweavetest.WriteWallet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+func TestTurboClient(t *testing.T) {
+	server := newTurboStub(t, map[string]string{})
+	client := newTestTurboClient(t, server, "")
+
+	balance, err := client.Balance(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Sign() != 0 {
+		t.Errorf("expected unknown wallets to have no credits, got %s", balance)
+	}
+
+	price, err := client.Price(context.Background(), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price.Int64() != 1024 {
+		t.Errorf("expected a price of 1024 winc, got %s", price)
+	}
+
+	funded := newTurboStub(t, map[string]string{client.address: "5000000000000"})
+	client = newTestTurboClient(t, funded, "")
+	balance, err = client.Balance(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatTokenAmount(balance) != "5.000000" {
+		t.Errorf("expected a balance of 5 credits, got %s", formatTokenAmount(balance))
+	}
+}
+
+func TestTurboClientErrors(t *testing.T) {
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/price/") {
+			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"winc": "a lot"}`))
+	})
+
+	client := newTestTurboClient(t, server, "")
+
+	_, err := client.Price(context.Background(), 1)
+	if err == nil || !strings.Contains(err.Error(), "service unavailable") {
+		t.Errorf("expected the service's error, got %v", err)
+	}
+
+	_, err = client.Balance(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "not a valid winc amount") {
+		t.Errorf("expected an invalid amount error, got %v", err)
+	}
+
+	_, err = NewTurboClient(UploadConfig{LowBalance: "-1"}, arweavetest.WriteWallet(t))
+	if err == nil {
+		t.Error("expected a negative low balance to be rejected")
+	}
+}
+
+func TestCheckTurboCredits(t *testing.T) {
+	walletPath := arweavetest.WriteWallet(t)
+	client, err := NewTurboClient(UploadConfig{}, walletPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := newTurboStub(t, map[string]string{client.address: "1000"})
+	uploads := ObjectStorageFiles{{Key: "a", Size: 600}, {Key: "b", Size: 300}}
+
+	for _, test := range []struct {
+		name       string
+		uploads    ObjectStorageFiles
+		lowBalance string
+		covered    bool
+	}{
+		{"covered", uploads, "", true},
+		{"covered below the low balance", uploads, "0.0000000005", true},
+		{"not covered", append(uploads, ObjectStorageFile{Key: "c", Size: 200}), "", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			run := &pipelineRun{
+				logger: log.NewTextLogger(slog.LevelError),
+				turbo:  newTestTurboClient(t, server, test.lowBalance),
+			}
+
+			covered, err := run.checkTurboCredits(context.Background(), test.uploads)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if covered != test.covered {
+				t.Errorf("expected covered to be %t, got %t", test.covered, covered)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/the-singularity-labs/cornelius/arweave"
+)
+
+const (
+	VerificationVerified = "verified"
+	VerificationFailed   = "failed"
+	VerificationSkipped  = "skipped"
+)
+
+// verifyUploads fetches the data of every upload that was not verified yet
+// from the gateway and compares its SHA-256 to the one of the source object.
+// Uploads the gateway does not serve yet are retried next iteration.
+func (run *pipelineRun) verifyUploads(ctx context.Context) error {
+	for _, fileState := range run.state.Files(run.pipeline.Name) {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if fileState.Verification != "" || fileState.DataTxId == "" || fileState.Sha256 == "" {
+			continue
+		}
+
+		logger := run.logger.With("object", fileState.Key, "data_tx_id", fileState.DataTxId)
+
+		verification := ""
+		if run.onOversize == OversizeSplit && fileState.Size > run.maxFileSize {
+			logger.Debug("skipping verification of split object")
+			verification = VerificationSkipped
+		} else {
+			sha256, err := dataSHA256(ctx, run.gateway, fileState.DataTxId)
+
+			var statusErr *arweave.StatusError
+			if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+				logger.Debug("data not served by gateway yet, verifying next iteration")
+				continue
+			} else if err != nil {
+				logger.Warn("unable to fetch data for verification", "error", err)
+				continue
+			}
+
+			verification = VerificationVerified
+			if sha256 != fileState.Sha256 {
+				logger.Error("uploaded data does not match source object", "sha256", fileState.Sha256, "gateway_sha256", sha256)
+				verification = VerificationFailed
+			} else {
+				logger.Info("upload verified", "sha256", sha256)
+			}
+		}
+
+		err := run.state.UpdateFile(run.pipeline.Name, fileState.Key, func(fileState *FileState) {
+			fileState.Verification = verification
+			fileState.VerifiedAt = time.Now()
+		})
+		if err != nil {
+			return fmt.Errorf("unable to record verification of %q: %w", fileState.Key, err)
+		}
+	}
+
+	return nil
+}
+
+func dataSHA256(ctx context.Context, gateway *arweave.Gateway, txId string) (string, error) {
+	data, err := gateway.Data(ctx, txId)
+	if err != nil {
+		return "", err
+	}
+	defer data.Close()
+
+	digest := sha256.New()
+	_, err = io.Copy(digest, data)
+	if err != nil {
+		return "", fmt.Errorf("unable to read data of %q: %w", txId, err)
+	}
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
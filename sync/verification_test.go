@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/the-singularity-labs/cornelius/arweave"
+	"github.com/the-singularity-labs/cornelius/log"
+)
+
+func TestVerifyUploads(t *testing.T) {
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/intact-tx", "/corrupt-tx":
+			w.Write([]byte("contents"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	state, err := NewStateStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256([]byte("contents"))
+	sha := hex.EncodeToString(digest[:])
+	for key, fileState := range map[string]FileState{
+		"intact.txt":   {DataTxId: "intact-tx", Sha256: sha, Size: 8},
+		"corrupt.txt":  {DataTxId: "corrupt-tx", Sha256: "other", Size: 8},
+		"unserved.txt": {DataTxId: "unserved-tx", Sha256: sha, Size: 8},
+		"split.tar":    {DataTxId: "split-tx", Sha256: sha, Size: 200},
+	} {
+		err = state.UpdateFile("site", key, func(state *FileState) {
+			*state = fileState
+			state.Key = key
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := &pipelineRun{
+		pipeline:    Pipeline{Name: "site"},
+		logger:      log.NewTextLogger(slog.LevelError),
+		state:       state,
+		gateway:     arweave.NewGateway(server.URL, server.URL, server.URL+"/graphql", time.Minute, time.Minute),
+		maxFileSize: 100,
+		onOversize:  OversizeSplit,
+	}
+
+	err = run.verifyUploads(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, verification := range map[string]string{
+		"intact.txt":   VerificationVerified,
+		"corrupt.txt":  VerificationFailed,
+		"unserved.txt": "",
+		"split.tar":    VerificationSkipped,
+	} {
+		fileState, _ := state.File("site", key)
+		if fileState.Verification != verification {
+			t.Errorf("expected %s to be %q, got %q", key, verification, fileState.Verification)
+		}
+		if verification != "" && fileState.VerifiedAt.IsZero() {
+			t.Errorf("expected the verification time of %s to be recorded", key)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/the-singularity-labs/cornelius/arweave"
+)
+
+const (
+	// estimated bytes the ArFS metadata of a file and the data item headers
+	// of its data and metadata add to an upload
+	arfsFileOverhead = 4 * 1024
+
+	// ArDrive community tip ardrive-cli adds to L1 uploads, a share of the
+	// data price with a minimum in winston
+	communityTipPercent = 15
+	minCommunityTip     = 10_000_000
+)
+
+// walletBalance fetches the AR balance of the pipeline's wallet, records it
+// as a metric and warns when it is below the low balance threshold.
+func (run *pipelineRun) walletBalance(ctx context.Context) (*big.Int, error) {
+	balance, err := run.gateway.Balance(ctx, run.walletAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get wallet balance: %w", err)
+	}
+
+	run.metrics.SetGauge("cornelius_wallet_balance_ar", "AR balance of the wallet of a pipeline.", map[string]string{
+		"pipeline": run.pipeline.Name,
+		"address":  run.walletAddress,
+	}, tokenAmountFloat(balance))
+
+	if balance.Cmp(run.lowBalance) < 0 {
+		run.logger.Warn("wallet balance is low", "address", run.walletAddress, "balance", formatTokenAmount(balance), "low_balance", formatTokenAmount(run.lowBalance))
+	} else {
+		run.logger.Debug("wallet balance", "address", run.walletAddress, "balance", formatTokenAmount(balance))
+	}
+
+	return balance, nil
+}
+
+// estimateUploadCost estimates the winston the wallet pays for uploading the
+// files as L1 transactions. ardrive-cli bundles the data and metadata of
+// every file into one transaction and adds the ArDrive community tip, split
+// objects are uploaded part by part. Files uploaded in bundles built by
+// Cornelius are paid for with one transaction per bundle without tip.
+func (run *pipelineRun) estimateUploadCost(ctx context.Context, uploads ObjectStorageFiles) (*big.Int, error) {
+	// the price only depends on the number of chunks, so it is fetched once
+	// per chunk count
+	prices := map[int64]*big.Int{}
+	price := func(bytes int64) (*big.Int, error) {
+		chunks := (bytes + arweave.MaxChunkSize - 1) / arweave.MaxChunkSize
+		if cached, exists := prices[chunks]; exists {
+			return cached, nil
+		}
+
+		winston, err := run.gateway.Price(ctx, int(chunks*arweave.MaxChunkSize))
+		if err != nil {
+			return nil, err
+		}
+
+		value, ok := new(big.Int).SetString(winston, 10)
+		if !ok {
+			return nil, fmt.Errorf("%q is not a valid price", winston)
+		}
+		prices[chunks] = value
+
+		return value, nil
+	}
+
+	cost := new(big.Int)
+	cliUploads := uploads
+	if run.bulk != nil {
+		var bundled ObjectStorageFiles
+		bundled, cliUploads = run.bulk.partition(uploads, run.maxFileSize)
+		for _, batch := range run.bulk.batches(bundled) {
+			bundleBytes := int64(0)
+			for _, file := range batch {
+				bundleBytes += file.Size + arfsFileOverhead
+			}
+
+			bundlePrice, err := price(bundleBytes)
+			if err != nil {
+				return nil, err
+			}
+			cost.Add(cost, bundlePrice)
+		}
+	}
+
+	for _, upload := range cliUploads {
+		parts := []int64{upload.Size}
+		if run.onOversize == OversizeSplit && upload.Size > run.maxFileSize {
+			parts = []int64{}
+			for remaining := upload.Size; remaining > 0; remaining -= run.maxFileSize {
+				parts = append(parts, min(remaining, run.maxFileSize))
+			}
+		}
+
+		for _, part := range parts {
+			partPrice, err := price(part + arfsFileOverhead)
+			if err != nil {
+				return nil, err
+			}
+
+			tip := new(big.Int).Mul(partPrice, big.NewInt(communityTipPercent))
+			tip.Div(tip, big.NewInt(100))
+			if tip.Cmp(big.NewInt(minCommunityTip)) < 0 {
+				tip.SetInt64(minCommunityTip)
+			}
+
+			cost.Add(cost, partPrice)
+			cost.Add(cost, tip)
+		}
+	}
+
+	return cost, nil
+}
+
+// checkWalletBalance reports whether the wallet can pay for the L1
+// transactions of the files to upload. Pipelines that cannot are paused
+// until the wallet has been topped up instead of failing upload by upload.
+func (run *pipelineRun) checkWalletBalance(ctx context.Context, uploads ObjectStorageFiles) (bool, error) {
+	balance, err := run.walletBalance(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if run.turbo != nil || len(uploads) == 0 {
+		run.setPaused(false)
+		return true, nil
+	}
+
+	cost, err := run.estimateUploadCost(ctx, uploads)
+	if err != nil {
+		return false, err
+	}
+
+	pendingBytes := int64(0)
+	for _, upload := range uploads {
+		pendingBytes += upload.Size
+	}
+
+	if balance.Cmp(cost) < 0 {
+		run.logger.Warn("wallet balance does not cover pending uploads, pausing uploads until it is topped up", "address", run.walletAddress, "balance", formatTokenAmount(balance), "estimated_cost", formatTokenAmount(cost), "files", len(uploads), "bytes", pendingBytes)
+		run.setPaused(true)
+		return false, nil
+	}
+
+	run.setPaused(false)
+	return true, nil
+}
+
+func (run *pipelineRun) setPaused(paused bool) {
+	value := 0.0
+	if paused {
+		value = 1
+	}
+
+	run.metrics.SetGauge("cornelius_pipeline_paused", "Whether uploads of a pipeline are paused for lack of funds.", map[string]string{
+		"pipeline": run.pipeline.Name,
+	}, value)
+}
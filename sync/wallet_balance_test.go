@@ -0,0 +1,120 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/the-singularity-labs/cornelius/arweave"
+	"github.com/the-singularity-labs/cornelius/log"
+)
+
+// newPricingStub serves prices of 50000000 winston per chunk and the balance
+// of the wallet, counting the price requests.
+func newPricingStub(t *testing.T, balance *string, priceRequests *int) *arweave.Gateway {
+	t.Helper()
+
+	server := newStubServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/price/"):
+			*priceRequests++
+			bytes, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/price/"))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintf(w, "%d", bytes/arweave.MaxChunkSize*50_000_000)
+		case r.URL.Path == "/wallet/address/balance":
+			w.Write([]byte(*balance))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	return arweave.NewGateway(server.URL, server.URL, server.URL+"/graphql", time.Minute, time.Minute)
+}
+
+func TestEstimateUploadCost(t *testing.T) {
+	balance := "0"
+	priceRequests := 0
+	run := &pipelineRun{
+		gateway:     newPricingStub(t, &balance, &priceRequests),
+		maxFileSize: 300 * 1024,
+		onOversize:  OversizeSplit,
+	}
+
+	// small files pay the minimum tip, the first part of the split object
+	// spans two chunks and pays 15 percent
+	uploads := ObjectStorageFiles{{Key: "a.txt", Size: 1000}, {Key: "b.txt", Size: 1000}, {Key: "c.tar", Size: 400 * 1024}}
+	cost, err := run.estimateUploadCost(context.Background(), uploads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost.String() != "295000000" {
+		t.Errorf("expected a cost of 295000000 winston, got %s", cost)
+	}
+	if priceRequests != 2 {
+		t.Errorf("expected one price request per chunk count, got %d", priceRequests)
+	}
+
+	// bundles pay one price per bundle and no tip
+	run.bulk, err = newBulkUploader(BulkConfig{MaxItems: 2}, nil, nil, "drive", "parent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploads = append(uploads, ObjectStorageFile{Key: "d.txt", Size: 1000})
+	cost, err = run.estimateUploadCost(context.Background(), uploads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost.String() != "275000000" {
+		t.Errorf("expected a cost of 275000000 winston, got %s", cost)
+	}
+}
+
+func TestCheckWalletBalance(t *testing.T) {
+	balance := "100000000"
+	priceRequests := 0
+	metrics := NewMetrics()
+	run := &pipelineRun{
+		pipeline:      Pipeline{Name: "site"},
+		logger:        log.NewTextLogger(slog.LevelError),
+		gateway:       newPricingStub(t, &balance, &priceRequests),
+		metrics:       metrics,
+		walletAddress: "address",
+		lowBalance:    big.NewInt(0),
+		maxFileSize:   300 * 1024,
+	}
+	paused := func() float64 {
+		t.Helper()
+		for _, gauge := range metrics.gauges["cornelius_pipeline_paused"] {
+			return gauge.value
+		}
+		t.Fatal("expected the paused gauge to be set")
+		return 0
+	}
+
+	uploads := ObjectStorageFiles{{Key: "a.txt", Size: 1000}, {Key: "b.txt", Size: 1000}}
+	canUpload, err := run.checkWalletBalance(context.Background(), uploads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canUpload || paused() != 1 {
+		t.Errorf("expected a balance below the cost of 120000000 winston to pause uploads, got %v", canUpload)
+	}
+
+	balance = "120000000"
+	canUpload, err = run.checkWalletBalance(context.Background(), uploads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !canUpload || paused() != 0 {
+		t.Errorf("expected a topped up wallet to resume uploads, got %v", canUpload)
+	}
+}
@@ -0,0 +1,139 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	WriteBackTags    = "tags"
+	WriteBackSidecar = "sidecar"
+
+	SidecarSuffix = ".arweave.json"
+
+	entityIdObjectTag = "arweave-entity-id"
+	dataTxIdObjectTag = "arweave-data-tx-id"
+)
+
+// WriteBackConfig configures how the Arweave location of uploaded objects is
+// written back to the source bucket, as object tags or as a sidecar object
+// next to each object.
+type WriteBackConfig struct {
+	Mode string `yaml:"mode"`
+}
+
+type objectSidecar struct {
+	EntityId     string    `json:"entity_id"`
+	DataTxId     string    `json:"data_tx_id"`
+	MetadataTxId string    `json:"metadata_tx_id"`
+	BundledIn    string    `json:"bundled_in,omitempty"`
+	Url          string    `json:"url"`
+	UploadedAt   time.Time `json:"uploaded_at"`
+}
+
+func (config WriteBackConfig) validate() error {
+	switch config.Mode {
+	case WriteBackTags, WriteBackSidecar:
+		return nil
+	default:
+		return fmt.Errorf("%q is not a valid write back mode, must be %q or %q", config.Mode, WriteBackTags, WriteBackSidecar)
+	}
+}
+
+// writeBack records the Arweave location of an uploaded object in the source
+// bucket. Objects that could not be written back are kept in the state and
+// retried on the next iteration rather than failing the upload, unless the
+// error is permanent, e.g. an object already carrying the maximum number of
+// tags.
+func (run *pipelineRun) writeBack(receipt Receipt) {
+	if run.pipeline.WriteBack == nil {
+		return
+	}
+
+	if run.tryWriteBack(receipt) {
+		run.writeBacks = append(run.writeBacks, receipt)
+		run.saveWriteBacks()
+	}
+}
+
+// retryWriteBacks retries the write backs that failed in previous iterations.
+func (run *pipelineRun) retryWriteBacks() {
+	if len(run.writeBacks) == 0 {
+		return
+	}
+
+	remaining := []Receipt{}
+	for _, receipt := range run.writeBacks {
+		if run.tryWriteBack(receipt) {
+			remaining = append(remaining, receipt)
+		}
+	}
+	run.writeBacks = remaining
+	run.saveWriteBacks()
+}
+
+// tryWriteBack writes back the location of the object and reports whether
+// it should be retried.
+func (run *pipelineRun) tryWriteBack(receipt Receipt) bool {
+	err := run.writeBackObject(receipt)
+	if err == nil {
+		return false
+	}
+
+	if classifyError(err) == ErrorPermanent {
+		run.logger.Error("unable to write back arweave location, giving up", "object", receipt.Key, "error", err)
+		return false
+	}
+
+	run.logger.Warn("unable to write back arweave location, retrying next iteration", "object", receipt.Key, "error", err)
+	return true
+}
+
+// restoreWriteBacks picks up the write backs a previous run did not finish.
+func (run *pipelineRun) restoreWriteBacks() {
+	run.writeBacks = run.state.Pipeline(run.pipeline.Name).WriteBacks
+}
+
+func (run *pipelineRun) saveWriteBacks() {
+	err := run.state.UpdatePipeline(run.pipeline.Name, func(pipelineState *PipelineState) {
+		pipelineState.WriteBacks = run.writeBacks
+	})
+	if err != nil {
+		run.logger.Warn("unable to record pending write backs", "error", err)
+	}
+}
+
+func (run *pipelineRun) writeBackObject(receipt Receipt) error {
+	if run.pipeline.WriteBack.Mode == WriteBackTags {
+		err := run.objConn.AddObjectTags(receipt.Key, map[string]string{
+			entityIdObjectTag: receipt.EntityId,
+			dataTxIdObjectTag: receipt.DataTxId,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to tag object: %w", err)
+		}
+
+		return nil
+	}
+
+	contents, err := json.Marshal(objectSidecar{
+		EntityId:     receipt.EntityId,
+		DataTxId:     receipt.DataTxId,
+		MetadataTxId: receipt.MetadataTxId,
+		BundledIn:    receipt.BundledIn,
+		Url:          strings.TrimSuffix(run.gatewayURL, "/") + "/" + receipt.DataTxId,
+		UploadedAt:   receipt.UploadedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to encode sidecar: %w", err)
+	}
+
+	err = run.objConn.PutObject(receipt.Key+SidecarSuffix, contents, "application/json")
+	if err != nil {
+		return fmt.Errorf("unable to write sidecar: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/the-singularity-labs/cornelius/log"
+)
+
+// newTaggingStub serves object tags like S3 and records the objects tagged.
+// Objects in tagCounts already carry that many tags.
+func newTaggingStub(t *testing.T, tagCounts map[string]int, tagged map[string]bool) *ObjectStorageConnection {
+	t.Helper()
+
+	return newStubBucket(t, func(w http.ResponseWriter, r *http.Request) {
+		if !r.URL.Query().Has("tagging") {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+
+		key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+		switch r.Method {
+		case http.MethodGet:
+			tagSet := ""
+			for i := 0; i < tagCounts[key]; i++ {
+				tagSet += fmt.Sprintf("<Tag><Key>tag-%d</Key><Value>value</Value></Tag>", i)
+			}
+			fmt.Fprintf(w, "<Tagging><TagSet>%s</TagSet></Tagging>", tagSet)
+		case http.MethodPut:
+			tagged[key] = true
+		}
+	})
+}
+
+func TestRetryWriteBacks(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	pipeline := Pipeline{Name: "site", WriteBack: &WriteBackConfig{Mode: WriteBackTags}}
+
+	state, err := NewStateStore(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = state.UpdatePipeline(pipeline.Name, func(pipelineState *PipelineState) {
+		pipelineState.WriteBacks = []Receipt{
+			{Key: "index.html", EntityId: "entity", DataTxId: "tx"},
+			{Key: "full.html", EntityId: "entity", DataTxId: "tx"},
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// S3 allows at most 10 tags per object, so the write back to full.html
+	// can never succeed
+	tagged := map[string]bool{}
+	state, err = NewStateStore(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := &pipelineRun{
+		pipeline: pipeline,
+		logger:   log.NewTextLogger(slog.LevelError),
+		state:    state,
+		objConn:  newTaggingStub(t, map[string]int{"full.html": 9}, tagged),
+	}
+	run.restoreWriteBacks()
+	if len(run.writeBacks) != 2 {
+		t.Fatalf("expected the write backs to be restored, got %+v", run.writeBacks)
+	}
+
+	run.retryWriteBacks()
+
+	if !tagged["index.html"] || tagged["full.html"] {
+		t.Errorf("expected only index.html to be tagged, got %v", tagged)
+	}
+	if len(run.writeBacks) != 0 {
+		t.Errorf("expected no write backs to remain, got %+v", run.writeBacks)
+	}
+	if writeBacks := state.Pipeline(pipeline.Name).WriteBacks; len(writeBacks) != 0 {
+		t.Errorf("expected the write backs to be cleared from the state, got %+v", writeBacks)
+	}
+}